@@ -0,0 +1,136 @@
+package pgxatomic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrNoTx is returned by functions which must be called with transaction in context.
+var ErrNoTx = errors.New("pgxatomic: no transaction in context")
+
+// ErrLockNotHeld is returned on unlock of advisory lock which was not held by session.
+var ErrLockNotHeld = errors.New("pgxatomic: advisory lock not held")
+
+// AdvisoryKey identifies advisory lock.
+type AdvisoryKey struct {
+	expr string
+	args []any
+}
+
+// Int64Key is advisory lock key of single bigint.
+func Int64Key(key int64) AdvisoryKey {
+	return AdvisoryKey{expr: "$1::bigint", args: []any{key}}
+}
+
+// Int32PairKey is advisory lock key of two integers.
+func Int32PairKey(key1, key2 int32) AdvisoryKey {
+	return AdvisoryKey{expr: "$1::integer, $2::integer", args: []any{key1, key2}}
+}
+
+// StringKey is advisory lock key of string hashed by hashtextextended on server.
+func StringKey(key string) AdvisoryKey {
+	return AdvisoryKey{expr: "hashtextextended($1, 0)", args: []any{key}}
+}
+
+func (k AdvisoryKey) sql(fn string) string {
+	return "SELECT " + fn + "(" + k.expr + ")"
+}
+
+// LockAdvisory obtains transaction level advisory lock, waiting if necessary.
+// Lock is released at the end of transaction from ctx, ErrNoTx is returned
+// if there is none.
+func LockAdvisory(ctx context.Context, key AdvisoryKey) error {
+	tx := txFromContext(ctx)
+	if tx == nil {
+		return ErrNoTx
+	}
+	_, err := tx.Exec(ctx, key.sql("pg_advisory_xact_lock"), key.args...)
+	return err
+}
+
+// TryLockAdvisory obtains transaction level advisory lock if it is available
+// and reports whether it is obtained. Lock is released at the end of
+// transaction from ctx, ErrNoTx is returned if there is none.
+func TryLockAdvisory(ctx context.Context, key AdvisoryKey) (bool, error) {
+	tx := txFromContext(ctx)
+	if tx == nil {
+		return false, ErrNoTx
+	}
+	var ok bool
+	err := tx.QueryRow(ctx, key.sql("pg_try_advisory_xact_lock"), key.args...).Scan(&ok)
+	return ok, err
+}
+
+// AdvisoryLock is session level advisory lock, it pins connection until Unlock.
+type AdvisoryLock struct {
+	conn *pgxpool.Conn
+	key  AdvisoryKey
+	once sync.Once
+}
+
+// LockAdvisory obtains session level advisory lock on dedicated connection,
+// waiting if necessary.
+func (p Pool) LockAdvisory(ctx context.Context, key AdvisoryKey) (*AdvisoryLock, error) {
+	conn, err := p.p.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Exec(ctx, key.sql("pg_advisory_lock"), key.args...); err != nil {
+		conn.Release()
+		return nil, err
+	}
+	return &AdvisoryLock{conn: conn, key: key}, nil
+}
+
+// TryLockAdvisory obtains session level advisory lock on dedicated connection
+// if it is available, nil lock is returned if it is not.
+func (p Pool) TryLockAdvisory(ctx context.Context, key AdvisoryKey) (*AdvisoryLock, bool, error) {
+	conn, err := p.p.Acquire(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	var ok bool
+	if err := conn.QueryRow(ctx, key.sql("pg_try_advisory_lock"), key.args...).Scan(&ok); err != nil || !ok {
+		conn.Release()
+		return nil, false, err
+	}
+	return &AdvisoryLock{conn: conn, key: key}, true, nil
+}
+
+// Unlock releases lock and returns connection to pool. If lock cannot be
+// released connection is closed, so server releases lock anyway.
+// Subsequent calls return ErrLockNotHeld.
+func (l *AdvisoryLock) Unlock(ctx context.Context) error {
+	err := ErrLockNotHeld
+	l.once.Do(func() {
+		err = l.unlock(ctx)
+	})
+	return err
+}
+
+// abandon closes connection of lock without unlocking, server releases lock
+// with session.
+func (l *AdvisoryLock) abandon(ctx context.Context) {
+	l.once.Do(func() {
+		_ = l.conn.Conn().Close(ctx)
+		l.conn.Release()
+	})
+}
+
+func (l *AdvisoryLock) unlock(ctx context.Context) error {
+	defer l.conn.Release()
+
+	var ok bool
+	if err := l.conn.QueryRow(ctx, l.key.sql("pg_advisory_unlock"), l.key.args...).Scan(&ok); err != nil {
+		_ = l.conn.Conn().Close(ctx)
+		return fmt.Errorf("pgxatomic: advisory unlock: %w", err)
+	}
+	if !ok {
+		return ErrLockNotHeld
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package pgxatomic
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestLockAdvisory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockTx := NewMockTx(ctrl)
+	ctx := WithTx(context.Background(), mockTx)
+
+	tests := []struct {
+		name     string
+		key      AdvisoryKey
+		wantSQL  string
+		wantArgs []any
+	}{
+		{
+			name:     "int64",
+			key:      Int64Key(42),
+			wantSQL:  "SELECT pg_advisory_xact_lock($1::bigint)",
+			wantArgs: []any{int64(42)},
+		},
+		{
+			name:     "int32 pair",
+			key:      Int32PairKey(1, 2),
+			wantSQL:  "SELECT pg_advisory_xact_lock($1::integer, $2::integer)",
+			wantArgs: []any{int32(1), int32(2)},
+		},
+		{
+			name:     "string",
+			key:      StringKey("orders"),
+			wantSQL:  "SELECT pg_advisory_xact_lock(hashtextextended($1, 0))",
+			wantArgs: []any{"orders"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockTx.EXPECT().Exec(gomock.Any(), tt.wantSQL, tt.wantArgs...).Return(pgconn.NewCommandTag("SELECT 1"), nil)
+			assert.NoError(t, LockAdvisory(ctx, tt.key))
+		})
+	}
+
+	assert.ErrorIs(t, LockAdvisory(context.Background(), Int64Key(1)), ErrNoTx)
+}
+
+func TestTryLockAdvisory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockTx := NewMockTx(ctrl)
+	ctx := WithTx(context.Background(), mockTx)
+
+	for _, want := range []bool{true, false} {
+		mockRow := NewMockRow(ctrl)
+		mockTx.EXPECT().QueryRow(gomock.Any(), "SELECT pg_try_advisory_xact_lock($1::bigint)", int64(7)).Return(mockRow)
+		mockRow.EXPECT().Scan(gomock.Any()).DoAndReturn(func(dest ...any) error {
+			*dest[0].(*bool) = want
+			return nil
+		})
+
+		got, err := TryLockAdvisory(ctx, Int64Key(7))
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	mockRow := NewMockRow(ctrl)
+	mockTx.EXPECT().QueryRow(gomock.Any(), gomock.Any(), gomock.Any()).Return(mockRow)
+	mockRow.EXPECT().Scan(gomock.Any()).Return(errTest)
+
+	got, err := TryLockAdvisory(ctx, StringKey("jobs"))
+	assert.ErrorIs(t, err, errTest)
+	assert.False(t, got)
+
+	got, err = TryLockAdvisory(context.Background(), Int64Key(7))
+	assert.ErrorIs(t, err, ErrNoTx)
+	assert.False(t, got)
+}
@@ -0,0 +1,19 @@
+package pgxatomic
+
+import "time"
+
+// ExponentialBackoff doubles delay starting from 1s up to 5m, it is default
+// backoff of outbox.Relay and queue.Worker.
+func ExponentialBackoff(attempt int) time.Duration {
+	const (
+		minDelay = time.Second
+		maxDelay = 5 * time.Minute
+	)
+	if attempt < 1 {
+		attempt = 1
+	}
+	if attempt > 20 {
+		return maxDelay
+	}
+	return min(minDelay<<(attempt-1), maxDelay)
+}
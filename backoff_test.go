@@ -0,0 +1,26 @@
+package pgxatomic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 0, want: time.Second},
+		{attempt: 1, want: time.Second},
+		{attempt: 2, want: 2 * time.Second},
+		{attempt: 5, want: 16 * time.Second},
+		{attempt: 9, want: 256 * time.Second},
+		{attempt: 10, want: 5 * time.Minute},
+		{attempt: 100, want: 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, ExponentialBackoff(tt.attempt), "attempt %d", tt.attempt)
+	}
+}
@@ -0,0 +1,111 @@
+package pgxatomic
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// CommitOutcome is known state of transaction which failed to commit.
+type CommitOutcome int
+
+const (
+	// CommitAborted means server rejected commit or commit was not sent,
+	// transaction is rolled back.
+	CommitAborted CommitOutcome = iota + 1
+
+	// CommitUnknown means connection failed before server acknowledged commit,
+	// transaction may be committed or rolled back.
+	CommitUnknown
+)
+
+func (o CommitOutcome) String() string {
+	switch o {
+	case CommitAborted:
+		return "aborted"
+	case CommitUnknown:
+		return "unknown"
+	default:
+		return "invalid"
+	}
+}
+
+// CommitError is returned by Runner.Run when transaction failed to commit.
+type CommitError struct {
+	Outcome CommitOutcome
+	Err     error
+
+	// StatusErr is error of transaction status check enabled with
+	// WithCommitStatusCheck if it failed.
+	StatusErr error
+}
+
+func (e *CommitError) Error() string {
+	return "pgxatomic: commit " + e.Outcome.String() + ": " + e.Err.Error()
+}
+
+func (e *CommitError) Unwrap() error { return e.Err }
+
+// commitStatusTimeout limits transaction status check, since context
+// of Run may already be canceled.
+const commitStatusTimeout = 5 * time.Second
+
+// WithCommitStatusCheck resolves unknown commit outcome by checking
+// txid_status() of transaction on fresh connection from db, pgxpool.Pool
+// implements db. Run returns nil if transaction turns out to be committed.
+// Enabling the check costs one additional query per transaction.
+func WithCommitStatusCheck(db queryRower) RunnerOption {
+	return func(r *Runner) {
+		r.statusDB = db
+	}
+}
+
+// currentXID returns transaction ID if status check is enabled and it is
+// assigned to tx, read-only transactions have none.
+func (r Runner) currentXID(ctx context.Context, tx pgx.Tx) (*int64, error) {
+	if r.statusDB == nil {
+		return nil, nil
+	}
+	var xid *int64
+	if err := tx.QueryRow(ctx, "SELECT txid_current_if_assigned()").Scan(&xid); err != nil {
+		return nil, err
+	}
+	return xid, nil
+}
+
+// commitError classifies commit error, nil is returned if status check
+// proves transaction is committed.
+func (r Runner) commitError(ctx context.Context, err error, xid *int64) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) || errors.Is(err, pgx.ErrTxCommitRollback) || pgconn.SafeToRetry(err) {
+		return &CommitError{Outcome: CommitAborted, Err: err}
+	}
+
+	cerr := &CommitError{Outcome: CommitUnknown, Err: err}
+	if xid == nil {
+		return cerr
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), commitStatusTimeout)
+	defer cancel()
+
+	var status *string
+	if err := r.statusDB.QueryRow(ctx, "SELECT txid_status($1)", *xid).Scan(&status); err != nil {
+		cerr.StatusErr = err
+		return cerr
+	}
+	if status == nil {
+		return cerr
+	}
+
+	switch *status {
+	case "committed":
+		return nil
+	case "aborted":
+		cerr.Outcome = CommitAborted
+	}
+	return cerr
+}
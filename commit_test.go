@@ -0,0 +1,164 @@
+package pgxatomic
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+type safeToRetryError struct{}
+
+func (safeToRetryError) Error() string     { return "conn busy" }
+func (safeToRetryError) SafeToRetry() bool { return true }
+
+func TestRun_CommitError(t *testing.T) {
+	errConnReset := errors.New("connection reset by peer")
+
+	tests := []struct {
+		name        string
+		commitErr   error
+		wantOutcome CommitOutcome
+	}{
+		{
+			name:        "server rejected",
+			commitErr:   &pgconn.PgError{Code: "40001"},
+			wantOutcome: CommitAborted,
+		},
+		{
+			name:        "commit resulted in rollback",
+			commitErr:   pgx.ErrTxCommitRollback,
+			wantOutcome: CommitAborted,
+		},
+		{
+			name:        "not sent",
+			commitErr:   safeToRetryError{},
+			wantOutcome: CommitAborted,
+		},
+		{
+			name:        "connection dropped",
+			commitErr:   errConnReset,
+			wantOutcome: CommitUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockDB := NewMockTxStarter(ctrl)
+			mockTx := NewMockTx(ctrl)
+
+			mockDB.EXPECT().BeginTx(gomock.Any(), gomock.Any()).Return(mockTx, nil)
+			mockTx.EXPECT().Commit(gomock.Any()).Return(tt.commitErr)
+
+			runner, err := NewRunner(mockDB, pgx.TxOptions{})
+			require.NoError(t, err)
+
+			err = runner.Run(context.Background(), func(ctx context.Context) error { return nil })
+
+			var commitErr *CommitError
+			require.ErrorAs(t, err, &commitErr)
+			assert.Equal(t, tt.wantOutcome, commitErr.Outcome)
+			assert.ErrorIs(t, err, tt.commitErr)
+		})
+	}
+}
+
+func TestRun_CommitStatusCheck(t *testing.T) {
+	errConnReset := errors.New("connection reset by peer")
+	xid := int64(42)
+
+	tests := []struct {
+		name        string
+		xid         *int64
+		status      *string
+		statusErr   error
+		wantErr     bool
+		wantOutcome CommitOutcome
+	}{
+		{
+			name:   "committed",
+			xid:    &xid,
+			status: ptr("committed"),
+		},
+		{
+			name:        "aborted",
+			xid:         &xid,
+			status:      ptr("aborted"),
+			wantErr:     true,
+			wantOutcome: CommitAborted,
+		},
+		{
+			name:        "in progress",
+			xid:         &xid,
+			status:      ptr("in progress"),
+			wantErr:     true,
+			wantOutcome: CommitUnknown,
+		},
+		{
+			name:        "status check failed",
+			xid:         &xid,
+			statusErr:   errTest,
+			wantErr:     true,
+			wantOutcome: CommitUnknown,
+		},
+		{
+			name:        "no xid assigned",
+			wantErr:     true,
+			wantOutcome: CommitUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockDB := NewMockTxStarter(ctrl)
+			mockTx := NewMockTx(ctrl)
+			mockStatusDB := NewMockTx(ctrl)
+			xidRow := NewMockRow(ctrl)
+
+			mockDB.EXPECT().BeginTx(gomock.Any(), gomock.Any()).Return(mockTx, nil)
+			mockTx.EXPECT().QueryRow(gomock.Any(), "SELECT txid_current_if_assigned()").Return(xidRow)
+			xidRow.EXPECT().Scan(gomock.Any()).DoAndReturn(func(dest ...any) error {
+				*dest[0].(**int64) = tt.xid
+				return nil
+			})
+			mockTx.EXPECT().Commit(gomock.Any()).Return(errConnReset)
+
+			if tt.xid != nil {
+				statusRow := NewMockRow(ctrl)
+				mockStatusDB.EXPECT().QueryRow(gomock.Any(), "SELECT txid_status($1)", xid).Return(statusRow)
+				statusRow.EXPECT().Scan(gomock.Any()).DoAndReturn(func(dest ...any) error {
+					*dest[0].(**string) = tt.status
+					return tt.statusErr
+				})
+			}
+
+			runner, err := NewRunner(mockDB, pgx.TxOptions{}, WithCommitStatusCheck(mockStatusDB))
+			require.NoError(t, err)
+
+			err = runner.Run(context.Background(), func(ctx context.Context) error { return nil })
+			if !tt.wantErr {
+				assert.NoError(t, err)
+				return
+			}
+
+			var commitErr *CommitError
+			require.ErrorAs(t, err, &commitErr)
+			assert.Equal(t, tt.wantOutcome, commitErr.Outcome)
+			assert.Equal(t, tt.statusErr, commitErr.StatusErr)
+			assert.ErrorIs(t, err, errConnReset)
+		})
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
@@ -2,6 +2,10 @@ package pgxatomic
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync/atomic"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 )
@@ -23,3 +27,71 @@ func TxFromContext(ctx context.Context) pgx.Tx {
 	}
 	return nil
 }
+
+// TxInfo describes transaction started by Runner.
+type TxInfo struct {
+	// Name set with WithTxName.
+	Name string
+	// ID is unique identifier shared by all attempts of single Run.
+	ID string
+	// StartedAt is time current attempt has begun.
+	StartedAt time.Time
+	// Options transaction was started with.
+	Options pgx.TxOptions
+	// Depth is number of Run calls the transaction is nested in, 0 for outermost one.
+	Depth int
+	// Attempt number starting from 1.
+	Attempt int
+}
+
+// TxInfoFromContext returns info of transaction started by Runner, false
+// is returned if ctx is not inside Run.
+func TxInfoFromContext(ctx context.Context) (TxInfo, bool) {
+	if ctx == nil {
+		return TxInfo{}, false
+	}
+	if st := txStateFromContext(ctx); st != nil {
+		return st.info, true
+	}
+	return TxInfo{}, false
+}
+
+type txStateKey struct{}
+
+// txState holds bookkeeping of transaction started by Runner.
+type txState struct {
+	info       TxInfo
+	statements atomic.Int64
+}
+
+func (s *txState) statementCount() int {
+	return int(s.statements.Load())
+}
+
+// newTxID returns random transaction identifier.
+func newTxID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+func withTxState(ctx context.Context, st *txState) context.Context {
+	return context.WithValue(ctx, txStateKey{}, st)
+}
+
+func txStateFromContext(ctx context.Context) *txState {
+	st, _ := ctx.Value(txStateKey{}).(*txState)
+	return st
+}
+
+// txFromContext returns pgx.Tx from context and counts statement executed in it.
+func txFromContext(ctx context.Context) pgx.Tx {
+	tx := TxFromContext(ctx)
+	if tx == nil {
+		return nil
+	}
+	if st := txStateFromContext(ctx); st != nil {
+		st.statements.Add(1)
+	}
+	return tx
+}
@@ -0,0 +1,96 @@
+// Package idempotency implements exactly-once request handling on top of
+// pgxatomic.Runner. Result of request is stored in the same transaction as
+// its changes and replayed for retried requests with the same key.
+// Migration must be applied before use.
+package idempotency
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/ysomad/pgxatomic"
+)
+
+// Migration creates idempotency keys table.
+//
+//go:embed migration.sql
+var Migration string
+
+// ErrEmptyKey is returned by Do if key is empty.
+var ErrEmptyKey = errors.New("idempotency: key cannot be empty")
+
+// Store keeps idempotency keys with results of requests.
+type Store struct {
+	runner pgxatomic.TxRunner
+}
+
+func NewStore(runner pgxatomic.TxRunner) *Store {
+	return &Store{runner: runner}
+}
+
+// Do runs fn in transaction once per key and stores its result marshaled to
+// JSON, result is unmarshaled and returned without running fn if key is
+// already stored. Concurrent calls with the same key wait on row lock until
+// the first one finishes. Error returned by fn rolls back transaction with
+// the key, so request may be retried.
+func Do[T any](ctx context.Context, s *Store, key string, fn func(ctx context.Context) (T, error)) (T, error) {
+	var res T
+	if key == "" {
+		return res, ErrEmptyKey
+	}
+
+	err := s.runner.Run(ctx, func(ctx context.Context) error {
+		tx := pgxatomic.TxFromContext(ctx)
+
+		if _, err := pgxatomic.Exec(ctx, tx,
+			"INSERT INTO pgxatomic_idempotency (key) VALUES ($1) ON CONFLICT DO NOTHING", key); err != nil {
+			return err
+		}
+
+		var stored []byte
+		if err := pgxatomic.QueryRow(ctx, tx,
+			"SELECT result FROM pgxatomic_idempotency WHERE key = $1 FOR UPDATE", key).Scan(&stored); err != nil {
+			return err
+		}
+		if stored != nil {
+			return json.Unmarshal(stored, &res)
+		}
+
+		v, err := fn(ctx)
+		if err != nil {
+			return err
+		}
+
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		if _, err := pgxatomic.Exec(ctx, tx,
+			"UPDATE pgxatomic_idempotency SET result = $2 WHERE key = $1", key, b); err != nil {
+			return err
+		}
+
+		res = v
+		return nil
+	}, pgxatomic.WithTxName("idempotency"))
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return res, nil
+}
+
+// Purge deletes keys stored before olderThan and returns number of deleted keys.
+func (s *Store) Purge(ctx context.Context, olderThan time.Time) (int64, error) {
+	var n int64
+	err := s.runner.Run(ctx, func(ctx context.Context) error {
+		tag, err := pgxatomic.Exec(ctx, pgxatomic.TxFromContext(ctx),
+			"DELETE FROM pgxatomic_idempotency WHERE created_at < $1", olderThan)
+		n = tag.RowsAffected()
+		return err
+	})
+	return n, err
+}
@@ -0,0 +1,140 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ysomad/pgxatomic"
+	"github.com/ysomad/pgxatomic/internal/pgtest"
+)
+
+// memDB is in-memory fake of idempotency table, changes of transaction are
+// visible after commit.
+type memDB struct {
+	stored map[string][]byte
+}
+
+func (db *memDB) BeginTx(context.Context, pgx.TxOptions) (pgx.Tx, error) {
+	return &memTx{db: db, pending: make(map[string][]byte)}, nil
+}
+
+type memTx struct {
+	pgx.Tx
+	db      *memDB
+	pending map[string][]byte
+}
+
+func (tx *memTx) Exec(_ context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	if strings.HasPrefix(sql, "UPDATE") {
+		tx.pending[args[0].(string)] = args[1].([]byte)
+	}
+	return pgconn.CommandTag{}, nil
+}
+
+func (tx *memTx) QueryRow(_ context.Context, _ string, args ...any) pgx.Row {
+	return memRow(tx.db.stored[args[0].(string)])
+}
+
+func (tx *memTx) Commit(context.Context) error {
+	for k, v := range tx.pending {
+		tx.db.stored[k] = v
+	}
+	return nil
+}
+
+func (tx *memTx) Rollback(context.Context) error { return nil }
+
+type memRow []byte
+
+func (r memRow) Scan(dest ...any) error {
+	*dest[0].(*[]byte) = r
+	return nil
+}
+
+type payment struct {
+	ID     int `json:"id"`
+	Amount int `json:"amount"`
+}
+
+func TestDo(t *testing.T) {
+	runner, err := pgxatomic.NewRunner(&memDB{stored: make(map[string][]byte)}, pgx.TxOptions{})
+	require.NoError(t, err)
+	s := NewStore(runner)
+	ctx := context.Background()
+
+	calls := 0
+	pay := func(ctx context.Context) (payment, error) {
+		calls++
+		assert.NotNil(t, pgxatomic.TxFromContext(ctx))
+		return payment{ID: calls, Amount: 100}, nil
+	}
+
+	_, err = Do(ctx, s, "key-1", func(ctx context.Context) (payment, error) {
+		return payment{}, errors.New("declined")
+	})
+	assert.EqualError(t, err, "declined")
+
+	got, err := Do(ctx, s, "key-1", pay)
+	require.NoError(t, err)
+	assert.Equal(t, payment{ID: 1, Amount: 100}, got)
+
+	got, err = Do(ctx, s, "key-1", pay)
+	require.NoError(t, err)
+	assert.Equal(t, payment{ID: 1, Amount: 100}, got, "stored result must be replayed")
+	assert.Equal(t, 1, calls)
+
+	got, err = Do(ctx, s, "key-2", pay)
+	require.NoError(t, err)
+	assert.Equal(t, payment{ID: 2, Amount: 100}, got)
+
+	_, err = Do(ctx, s, "", pay)
+	assert.ErrorIs(t, err, ErrEmptyKey)
+}
+
+func TestDo_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	pool := pgtest.Pool(t)
+
+	_, err := pool.Exec(ctx, Migration)
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, "DELETE FROM pgxatomic_idempotency WHERE key = 'concurrent'")
+	require.NoError(t, err)
+
+	runner, err := pgxatomic.NewRunner(pool, pgx.TxOptions{})
+	require.NoError(t, err)
+	s := NewStore(runner)
+
+	var calls atomic.Int32
+	var wg sync.WaitGroup
+	results := make([]payment, 10)
+
+	for i := range results {
+		wg.Go(func() {
+			res, err := Do(ctx, s, "concurrent", func(ctx context.Context) (payment, error) {
+				return payment{ID: int(calls.Add(1)), Amount: 100}, nil
+			})
+			assert.NoError(t, err)
+			results[i] = res
+		})
+	}
+	wg.Wait()
+
+	assert.EqualValues(t, 1, calls.Load())
+	for _, res := range results {
+		assert.Equal(t, payment{ID: 1, Amount: 100}, res)
+	}
+
+	n, err := s.Purge(ctx, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, n, int64(1))
+}
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS pgxatomic_idempotency (
+    key        text        PRIMARY KEY,
+    result     jsonb,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
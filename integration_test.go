@@ -0,0 +1,188 @@
+package pgxatomic_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ysomad/pgxatomic"
+	"github.com/ysomad/pgxatomic/internal/pgtest"
+)
+
+var errTest = errors.New("test error")
+
+func createTable(t *testing.T, pool *pgxpool.Pool, name string) {
+	t.Helper()
+
+	ctx := context.Background()
+	_, err := pool.Exec(ctx, "DROP TABLE IF EXISTS "+name+"; CREATE TABLE "+name+" (id int PRIMARY KEY)")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_, _ = pool.Exec(context.Background(), "DROP TABLE IF EXISTS "+name)
+	})
+}
+
+func countRows(t *testing.T, pool *pgxpool.Pool, table string) int {
+	t.Helper()
+
+	var n int
+	require.NoError(t, pool.QueryRow(context.Background(), "SELECT count(*) FROM "+table).Scan(&n))
+	return n
+}
+
+func TestIntegration_Run(t *testing.T) {
+	pool := pgtest.Pool(t)
+	createTable(t, pool, "pgxatomic_it_run")
+
+	runner, err := pgxatomic.NewRunner(pool, pgx.TxOptions{})
+	require.NoError(t, err)
+
+	insert := func(id int, fnErr error) error {
+		return runner.Run(context.Background(), func(ctx context.Context) error {
+			if _, err := pgxatomic.Exec(ctx, pool, "INSERT INTO pgxatomic_it_run VALUES ($1)", id); err != nil {
+				return err
+			}
+			return fnErr
+		})
+	}
+
+	require.NoError(t, insert(1, nil))
+	assert.ErrorIs(t, insert(2, errTest), errTest)
+	assert.Equal(t, 1, countRows(t, pool, "pgxatomic_it_run"))
+}
+
+func TestIntegration_Savepoint(t *testing.T) {
+	pool := pgtest.Pool(t)
+	createTable(t, pool, "pgxatomic_it_savepoint")
+
+	runner, err := pgxatomic.NewRunner(pool, pgx.TxOptions{}, pgxatomic.WithPropagation(pgxatomic.PropagationNested))
+	require.NoError(t, err)
+
+	err = runner.Run(context.Background(), func(ctx context.Context) error {
+		if _, err := pgxatomic.Exec(ctx, pool, "INSERT INTO pgxatomic_it_savepoint VALUES (1)"); err != nil {
+			return err
+		}
+
+		// duplicate key aborts only savepoint
+		err := runner.Run(ctx, func(ctx context.Context) error {
+			_, err := pgxatomic.Exec(ctx, pool, "INSERT INTO pgxatomic_it_savepoint VALUES (1)")
+			return err
+		})
+		assert.Error(t, err)
+
+		_, err = pgxatomic.Exec(ctx, pool, "INSERT INTO pgxatomic_it_savepoint VALUES (2)")
+		return err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, countRows(t, pool, "pgxatomic_it_savepoint"))
+}
+
+func TestIntegration_AdvisoryLock(t *testing.T) {
+	pool := pgtest.Pool(t)
+
+	runner, err := pgxatomic.NewRunner(pool, pgx.TxOptions{})
+	require.NoError(t, err)
+
+	key := pgxatomic.StringKey("pgxatomic_it_advisory")
+
+	err = runner.Run(context.Background(), func(ctx context.Context) error {
+		if err := pgxatomic.LockAdvisory(ctx, key); err != nil {
+			return err
+		}
+
+		return runner.Run(context.Background(), func(ctx context.Context) error {
+			ok, err := pgxatomic.TryLockAdvisory(ctx, key)
+			assert.False(t, ok)
+			return err
+		})
+	})
+	require.NoError(t, err)
+
+	// lock is released on commit
+	err = runner.Run(context.Background(), func(ctx context.Context) error {
+		ok, err := pgxatomic.TryLockAdvisory(ctx, key)
+		assert.True(t, ok)
+		return err
+	})
+	require.NoError(t, err)
+}
+
+func TestIntegration_Retry(t *testing.T) {
+	pool := pgtest.Pool(t)
+	createTable(t, pool, "pgxatomic_it_retry")
+
+	runner, err := pgxatomic.NewRunner(pool, pgx.TxOptions{IsoLevel: pgx.Serializable}, pgxatomic.WithRetry(3))
+	require.NoError(t, err)
+
+	var attempts int
+	err = runner.Run(context.Background(), func(ctx context.Context) error {
+		attempts++
+		if _, err := pgxatomic.Exec(ctx, pool, "INSERT INTO pgxatomic_it_retry VALUES (1)"); err != nil {
+			return err
+		}
+		if attempts == 1 {
+			_, err := pgxatomic.Exec(ctx, pool, "DO $$ BEGIN RAISE EXCEPTION 'conflict' USING ERRCODE = '40001'; END $$")
+			return err
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+	assert.Equal(t, 1, countRows(t, pool, "pgxatomic_it_retry"))
+}
+
+func TestIntegration_LockNoWait(t *testing.T) {
+	pool := pgtest.Pool(t)
+	createTable(t, pool, "pgxatomic_it_rowlock")
+
+	_, err := pool.Exec(context.Background(), "INSERT INTO pgxatomic_it_rowlock VALUES (1)")
+	require.NoError(t, err)
+
+	runner, err := pgxatomic.NewRunner(pool, pgx.TxOptions{})
+	require.NoError(t, err)
+
+	const query = "SELECT id FROM pgxatomic_it_rowlock WHERE id = $1"
+
+	err = runner.Run(context.Background(), func(ctx context.Context) error {
+		if _, err := pgxatomic.LockOne[int](ctx, pool, query, pgxatomic.ForUpdate, 1); err != nil {
+			return err
+		}
+
+		// single column struct types are scanned as value
+		id, err := pgxatomic.LockOne[pgtype.Text](ctx, pool, "SELECT id::text FROM pgxatomic_it_rowlock WHERE id = $1", pgxatomic.ForShare, 1)
+		if err != nil {
+			return err
+		}
+		assert.Equal(t, pgtype.Text{String: "1", Valid: true}, id)
+
+		err = runner.Run(context.Background(), func(ctx context.Context) error {
+			_, err := pgxatomic.LockOne[int](ctx, pool, query, pgxatomic.ForUpdate.NoWait(), 1)
+			return err
+		})
+		assert.ErrorIs(t, err, pgxatomic.ErrLockNotAvailable)
+
+		err = runner.Run(context.Background(), func(ctx context.Context) error {
+			if err := pgxatomic.SetLockTimeout(ctx, 10*time.Millisecond); err != nil {
+				return err
+			}
+			_, err := pgxatomic.LockOne[int](ctx, pool, query, pgxatomic.ForUpdate, 1)
+			return err
+		})
+		assert.ErrorIs(t, err, pgxatomic.ErrLockNotAvailable)
+
+		err = runner.Run(context.Background(), func(ctx context.Context) error {
+			_, err := pgxatomic.LockOne[int](ctx, pool, query, pgxatomic.ForUpdate.SkipLocked(), 1)
+			return err
+		})
+		assert.ErrorIs(t, err, pgx.ErrNoRows)
+		return nil
+	})
+	require.NoError(t, err)
+}
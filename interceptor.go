@@ -0,0 +1,36 @@
+package pgxatomic
+
+import "context"
+
+// Interceptor wraps transaction execution, it must call next to proceed.
+type Interceptor func(ctx context.Context, info TxInfo, next func(ctx context.Context) error) error
+
+// WithInterceptors registers interceptors executed around txFunc inside
+// transaction, first registered interceptor is the outermost one.
+func WithInterceptors(interceptors ...Interceptor) RunnerOption {
+	return func(r *Runner) {
+		r.interceptors = append(r.interceptors, interceptors...)
+	}
+}
+
+// WithBoundaryInterceptors registers interceptors executed around begin and
+// commit of each attempt outside of transaction, first registered interceptor
+// is the outermost one. Info passed to them has zero StartedAt since
+// transaction is not started yet, calling next more than once starts new
+// transaction on each call.
+func WithBoundaryInterceptors(interceptors ...Interceptor) RunnerOption {
+	return func(r *Runner) {
+		r.boundaryInterceptors = append(r.boundaryInterceptors, interceptors...)
+	}
+}
+
+// chain wraps fn in interceptors preserving registration order.
+func chain(interceptors []Interceptor, info TxInfo, fn func(ctx context.Context) error) func(ctx context.Context) error {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		ic, next := interceptors[i], fn
+		fn = func(ctx context.Context) error {
+			return ic(ctx, info, next)
+		}
+	}
+	return fn
+}
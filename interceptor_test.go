@@ -0,0 +1,132 @@
+package pgxatomic
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func recordingInterceptor(name string, calls *[]string) Interceptor {
+	return func(ctx context.Context, info TxInfo, next func(ctx context.Context) error) error {
+		*calls = append(*calls, name+":before:"+boolString(TxFromContext(ctx) != nil))
+		err := next(ctx)
+		*calls = append(*calls, name+":after")
+		return err
+	}
+}
+
+func boolString(b bool) string {
+	if b {
+		return "tx"
+	}
+	return "no_tx"
+}
+
+func TestRun_Interceptors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := NewMockTxStarter(ctrl)
+	mockTx := NewMockTx(ctrl)
+
+	var calls []string
+
+	mockDB.EXPECT().BeginTx(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(context.Context, pgx.TxOptions) (pgx.Tx, error) {
+			calls = append(calls, "begin")
+			return mockTx, nil
+		})
+	mockTx.EXPECT().Commit(gomock.Any()).
+		DoAndReturn(func(context.Context) error {
+			calls = append(calls, "commit")
+			return nil
+		})
+
+	runner, err := NewRunner(mockDB, pgx.TxOptions{},
+		WithInterceptors(recordingInterceptor("in1", &calls)),
+		WithBoundaryInterceptors(recordingInterceptor("out1", &calls), recordingInterceptor("out2", &calls)),
+		WithInterceptors(recordingInterceptor("in2", &calls)),
+	)
+	assert.NoError(t, err)
+
+	err = runner.Run(context.Background(), func(ctx context.Context) error {
+		calls = append(calls, "txFunc")
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"out1:before:no_tx",
+		"out2:before:no_tx",
+		"begin",
+		"in1:before:tx",
+		"in2:before:tx",
+		"txFunc",
+		"in2:after",
+		"in1:after",
+		"commit",
+		"out2:after",
+		"out1:after",
+	}, calls)
+}
+
+func TestRun_InterceptorShortCircuit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := NewMockTxStarter(ctrl)
+	mockTx := NewMockTx(ctrl)
+
+	mockDB.EXPECT().BeginTx(gomock.Any(), gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().Rollback(gomock.Any()).Return(nil)
+
+	runner, err := NewRunner(mockDB, pgx.TxOptions{},
+		WithInterceptors(func(ctx context.Context, info TxInfo, next func(ctx context.Context) error) error {
+			assert.Equal(t, "withdraw", info.Name)
+			assert.False(t, info.StartedAt.IsZero())
+			return errTest
+		}),
+	)
+	assert.NoError(t, err)
+
+	err = runner.Run(context.Background(), func(ctx context.Context) error {
+		t.Fatal("txFunc must not be called")
+		return nil
+	}, WithTxName("withdraw"))
+	assert.ErrorIs(t, err, errTest)
+}
+
+func TestRun_BoundaryInterceptorRetry(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := NewMockTxStarter(ctrl)
+	mockTx := NewMockTx(ctrl)
+
+	mockDB.EXPECT().BeginTx(gomock.Any(), gomock.Any()).Return(mockTx, nil).Times(2)
+	mockTx.EXPECT().Rollback(gomock.Any()).Return(nil)
+	mockTx.EXPECT().Commit(gomock.Any()).Return(nil)
+
+	runner, err := NewRunner(mockDB, pgx.TxOptions{},
+		WithBoundaryInterceptors(func(ctx context.Context, info TxInfo, next func(ctx context.Context) error) error {
+			if err := next(ctx); err == nil {
+				return nil
+			}
+			return next(ctx)
+		}),
+	)
+	assert.NoError(t, err)
+
+	calls := 0
+	err = runner.Run(context.Background(), func(ctx context.Context) error {
+		calls++
+		if calls == 1 {
+			return errTest
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,230 @@
+// Package pgtest provides database for tests of this module, pgxatomictest
+// exposes it to users and internal tests of pgxatomic use it directly since
+// they cannot import pgxatomictest.
+package pgtest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Option configures server started by Start.
+type Option func(*config)
+
+type config struct {
+	settings     map[string]string
+	startTimeout time.Duration
+}
+
+// WithSetting sets server configuration parameter passed with -c.
+func WithSetting(name, value string) Option {
+	return func(c *config) {
+		c.settings[name] = value
+	}
+}
+
+// WithStartTimeout sets how long Start waits for server to accept
+// connections, 30 seconds by default.
+func WithStartTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.startTimeout = d
+	}
+}
+
+// Start initializes database cluster with initdb in temporary
+// directory, starts postgres on random local port and returns pool connected
+// to it. Server is stopped and its data removed in t.Cleanup. Test is skipped
+// if initdb or postgres binary is not found on PATH or in
+// /usr/lib/postgresql/*/bin, or if it runs as root which postgres refuses.
+func Start(t testing.TB, opts ...Option) *pgxpool.Pool {
+	t.Helper()
+
+	cfg := config{
+		settings: map[string]string{
+			"fsync":                     "off",
+			"synchronous_commit":        "off",
+			"full_page_writes":          "off",
+			"max_prepared_transactions": "10",
+		},
+		startTimeout: 30 * time.Second,
+	}
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	initdb, postgres, err := lookPostgres()
+	if err != nil {
+		t.Skip("pgxatomictest: " + err.Error())
+	}
+
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data")
+
+	out, err := exec.Command(initdb, "-D", data, "-U", "postgres", "-A", "trust", "-E", "UTF8", "--no-locale", "--no-sync").CombinedOutput()
+	if err != nil {
+		t.Fatalf("pgxatomictest: initdb: %s\n%s", err, out)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		t.Fatalf("pgxatomictest: free port: %s", err)
+	}
+
+	args := []string{"-D", data, "-p", fmt.Sprint(port), "-k", dir, "-c", "listen_addresses=127.0.0.1"}
+	names := make([]string, 0, len(cfg.settings))
+	for name := range cfg.settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		args = append(args, "-c", name+"="+cfg.settings[name])
+	}
+
+	var log lockedBuffer
+	cmd := exec.Command(postgres, args...)
+	cmd.Stdout = &log
+	cmd.Stderr = &log
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("pgxatomictest: start postgres: %s", err)
+	}
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	t.Cleanup(func() {
+		// SIGINT requests fast shutdown which aborts active transactions
+		_ = cmd.Process.Signal(os.Interrupt)
+		select {
+		case <-exited:
+		case <-time.After(10 * time.Second):
+			_ = cmd.Process.Kill()
+			<-exited
+		}
+	})
+
+	connString := fmt.Sprintf("postgres://postgres@127.0.0.1:%d/postgres?sslmode=disable", port)
+
+	pool, err := pgxpool.New(context.Background(), connString)
+	if err != nil {
+		t.Fatalf("pgxatomictest: %s", err)
+	}
+	t.Cleanup(pool.Close)
+
+	deadline := time.Now().Add(cfg.startTimeout)
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		err := pool.Ping(ctx)
+		cancel()
+		if err == nil {
+			return pool
+		}
+
+		select {
+		case exitErr := <-exited:
+			exited <- exitErr
+			t.Fatalf("pgxatomictest: postgres exited: %v\n%s", exitErr, log.String())
+		default:
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("pgxatomictest: postgres is not ready after %s: %s\n%s", cfg.startTimeout, err, log.String())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// envDatabaseURL is environment variable with URL of database used by Pool
+// instead of starting ephemeral one.
+const envDatabaseURL = "PGXATOMIC_TEST_DATABASE_URL"
+
+// Pool connects to database from envDatabaseURL if it is set and starts
+// ephemeral one with Start otherwise.
+func Pool(t testing.TB) *pgxpool.Pool {
+	t.Helper()
+
+	url := os.Getenv(envDatabaseURL)
+	if url == "" {
+		if _, _, err := lookPostgres(); err != nil {
+			t.Skipf("pgxatomictest: %s is not set and ephemeral postgres cannot be started: %s", envDatabaseURL, err)
+		}
+		return Start(t)
+	}
+
+	pool, err := pgxpool.New(context.Background(), url)
+	if err != nil {
+		t.Fatalf("pgxatomictest: %s", err)
+	}
+	t.Cleanup(pool.Close)
+
+	return pool
+}
+
+// lookPostgres returns paths of initdb and postgres binaries, error is
+// returned if they are not found or cannot be run by current user.
+func lookPostgres() (initdb, postgres string, err error) {
+	if os.Geteuid() == 0 {
+		return "", "", errors.New("postgres cannot be run as root")
+	}
+
+	if initdb, err := exec.LookPath("initdb"); err == nil {
+		if postgres, err := exec.LookPath("postgres"); err == nil {
+			return initdb, postgres, nil
+		}
+	}
+
+	// Debian and Ubuntu do not put server binaries on PATH
+	dirs, _ := filepath.Glob("/usr/lib/postgresql/*/bin")
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+	for _, dir := range dirs {
+		initdb, postgres := filepath.Join(dir, "initdb"), filepath.Join(dir, "postgres")
+		if isExecutable(initdb) && isExecutable(postgres) {
+			return initdb, postgres, nil
+		}
+	}
+
+	return "", "", errors.New("initdb and postgres are not found")
+}
+
+func isExecutable(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && !fi.IsDir() && fi.Mode()&0o111 != 0
+}
+
+func freePort() (int, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port, nil
+}
+
+// lockedBuffer collects server output written while it is read on failure.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,141 @@
+package pgxatomic
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// LogLevels sets level of each logged event.
+type LogLevels struct {
+	Begin       slog.Level
+	BeginFailed slog.Level
+	Commit      slog.Level
+	Rollback    slog.Level
+	Retry       slog.Level
+	Slow        slog.Level
+	Query       slog.Level
+}
+
+// DefaultLogLevels logs successful lifecycle events and queries at debug level,
+// rollbacks, retries and slow transactions at warn and begin failures at error level.
+func DefaultLogLevels() LogLevels {
+	return LogLevels{
+		Begin:       slog.LevelDebug,
+		BeginFailed: slog.LevelError,
+		Commit:      slog.LevelDebug,
+		Rollback:    slog.LevelWarn,
+		Retry:       slog.LevelWarn,
+		Slow:        slog.LevelWarn,
+		Query:       slog.LevelDebug,
+	}
+}
+
+// LogOptions configures logging of transaction lifecycle and queries.
+type LogOptions struct {
+	// Levels of logged events, DefaultLogLevels is used if nil.
+	Levels *LogLevels
+
+	// SlowThreshold is duration after which transaction is logged as slow,
+	// slow transactions are not logged if zero.
+	SlowThreshold time.Duration
+
+	// RedactArgs is applied to query arguments before they are logged,
+	// arguments are not logged if nil. Use KeepArgs to log them as is.
+	RedactArgs func(sql string, args []any) []any
+}
+
+// KeepArgs returns query arguments unchanged.
+func KeepArgs(_ string, args []any) []any { return args }
+
+type txLogger struct {
+	l      *slog.Logger
+	levels LogLevels
+	opts   LogOptions
+}
+
+func newTxLogger(l *slog.Logger, opts LogOptions) *txLogger {
+	if l == nil {
+		return nil
+	}
+	levels := DefaultLogLevels()
+	if opts.Levels != nil {
+		levels = *opts.Levels
+	}
+	return &txLogger{l: l, levels: levels, opts: opts}
+}
+
+func txAttrs(st *txState, attrs ...slog.Attr) []slog.Attr {
+	if st == nil {
+		return attrs
+	}
+	return append([]slog.Attr{
+		slog.String("tx_id", st.info.ID),
+		slog.String("tx_name", st.info.Name),
+	}, attrs...)
+}
+
+func (l *txLogger) begin(ctx context.Context, st *txState) {
+	if l == nil {
+		return
+	}
+	l.l.LogAttrs(ctx, l.levels.Begin, "pgxatomic: tx begin",
+		txAttrs(st, slog.Int("attempt", st.info.Attempt), slog.Int("depth", st.info.Depth))...)
+}
+
+func (l *txLogger) beginFailed(ctx context.Context, st *txState, err error) {
+	if l == nil {
+		return
+	}
+	l.l.LogAttrs(ctx, l.levels.BeginFailed, "pgxatomic: tx begin failed",
+		txAttrs(st, slog.Int("attempt", st.info.Attempt), slog.Any("error", err))...)
+}
+
+func (l *txLogger) commit(ctx context.Context, st *txState, d time.Duration) {
+	if l == nil {
+		return
+	}
+	l.l.LogAttrs(ctx, l.levels.Commit, "pgxatomic: tx commit",
+		txAttrs(st, slog.Duration("duration", d), slog.Int("statements", st.statementCount()))...)
+	l.slow(ctx, st, d)
+}
+
+func (l *txLogger) rollback(ctx context.Context, st *txState, d time.Duration, cause error) {
+	if l == nil {
+		return
+	}
+	l.l.LogAttrs(ctx, l.levels.Rollback, "pgxatomic: tx rollback",
+		txAttrs(st, slog.Duration("duration", d), slog.Int("statements", st.statementCount()), slog.Any("error", cause))...)
+	l.slow(ctx, st, d)
+}
+
+func (l *txLogger) slow(ctx context.Context, st *txState, d time.Duration) {
+	if l.opts.SlowThreshold <= 0 || d < l.opts.SlowThreshold {
+		return
+	}
+	l.l.LogAttrs(ctx, l.levels.Slow, "pgxatomic: slow tx",
+		txAttrs(st, slog.Duration("duration", d), slog.Duration("threshold", l.opts.SlowThreshold))...)
+}
+
+func (l *txLogger) retry(ctx context.Context, st *txState, attempt int, err error) {
+	if l == nil {
+		return
+	}
+	l.l.LogAttrs(ctx, l.levels.Retry, "pgxatomic: tx retry",
+		txAttrs(st, slog.Int("attempt", attempt), slog.Any("error", err))...)
+}
+
+// query logs statement executed inside transaction.
+func (l *txLogger) query(ctx context.Context, sql string, args []any, d time.Duration, err error) {
+	if l == nil || TxFromContext(ctx) == nil || !l.l.Enabled(ctx, l.levels.Query) {
+		return
+	}
+	attrs := txAttrs(txStateFromContext(ctx), slog.String("sql", sql), slog.Duration("duration", d))
+	if l.opts.RedactArgs != nil {
+		attrs = append(attrs, slog.Any("args", l.opts.RedactArgs(sql, args)))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	l.l.LogAttrs(ctx, l.levels.Query, "pgxatomic: query", attrs...)
+}
@@ -0,0 +1,172 @@
+package pgxatomic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func newTestLogger(buf *bytes.Buffer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+func decodeLogs(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	var logs []map[string]any
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var m map[string]any
+		require.NoError(t, dec.Decode(&m))
+		logs = append(logs, m)
+	}
+	return logs
+}
+
+func TestRun_Logger(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := NewMockTxStarter(ctrl)
+	mockTx := NewMockTx(ctrl)
+	serializationErr := &pgconn.PgError{Code: "40001"}
+
+	mockDB.EXPECT().BeginTx(gomock.Any(), gomock.Any()).Return(mockTx, nil).Times(2)
+	mockTx.EXPECT().Rollback(gomock.Any()).Return(nil)
+	mockTx.EXPECT().Commit(gomock.Any()).Return(nil)
+
+	var buf bytes.Buffer
+	runner, err := NewRunner(mockDB, pgx.TxOptions{},
+		WithRetry(2),
+		WithLogger(newTestLogger(&buf), LogOptions{SlowThreshold: 1}),
+	)
+	require.NoError(t, err)
+
+	calls := 0
+	err = runner.Run(context.Background(), func(ctx context.Context) error {
+		calls++
+		if calls == 1 {
+			return serializationErr
+		}
+		return nil
+	}, WithTxName("withdraw"))
+	require.NoError(t, err)
+
+	logs := decodeLogs(t, &buf)
+
+	var msgs []string
+	for _, l := range logs {
+		msgs = append(msgs, l["level"].(string)+" "+l["msg"].(string))
+		assert.Equal(t, logs[0]["tx_id"], l["tx_id"])
+		assert.Equal(t, "withdraw", l["tx_name"])
+	}
+	assert.Equal(t, []string{
+		"DEBUG pgxatomic: tx begin",
+		"WARN pgxatomic: tx rollback",
+		"WARN pgxatomic: slow tx",
+		"WARN pgxatomic: tx retry",
+		"DEBUG pgxatomic: tx begin",
+		"DEBUG pgxatomic: tx commit",
+		"WARN pgxatomic: slow tx",
+	}, msgs)
+	assert.NotEmpty(t, logs[0]["tx_id"])
+	assert.Equal(t, serializationErr.Error(), logs[1]["error"])
+	assert.EqualValues(t, 2, logs[3]["attempt"])
+}
+
+func TestRun_LoggerLevels(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := NewMockTxStarter(ctrl)
+
+	mockDB.EXPECT().BeginTx(gomock.Any(), gomock.Any()).Return(nil, errTest)
+
+	levels := DefaultLogLevels()
+	levels.BeginFailed = slog.LevelWarn
+
+	var buf bytes.Buffer
+	runner, err := NewRunner(mockDB, pgx.TxOptions{}, WithLogger(newTestLogger(&buf), LogOptions{Levels: &levels}))
+	require.NoError(t, err)
+
+	err = runner.Run(context.Background(), func(ctx context.Context) error { return nil })
+	assert.ErrorIs(t, err, errTest)
+
+	logs := decodeLogs(t, &buf)
+	require.Len(t, logs, 1)
+	assert.Equal(t, "WARN", logs[0]["level"])
+	assert.Equal(t, "pgxatomic: tx begin failed", logs[0]["msg"])
+}
+
+func TestPool_QueryLogger(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockTx := NewMockTx(ctrl)
+
+	tests := []struct {
+		name     string
+		opts     LogOptions
+		ctx      context.Context
+		wantLogs int
+		wantArgs any
+	}{
+		{
+			name: "outside tx",
+			ctx:  context.Background(),
+		},
+		{
+			name:     "args omitted",
+			ctx:      WithTx(context.Background(), mockTx),
+			wantLogs: 1,
+		},
+		{
+			name:     "args kept",
+			opts:     LogOptions{RedactArgs: KeepArgs},
+			ctx:      WithTx(context.Background(), mockTx),
+			wantLogs: 1,
+			wantArgs: []any{"secret"},
+		},
+		{
+			name: "args redacted",
+			opts: LogOptions{RedactArgs: func(_ string, args []any) []any {
+				return []any{"***"}
+			}},
+			ctx:      WithTx(context.Background(), mockTx),
+			wantLogs: 1,
+			wantArgs: []any{"***"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			p := Pool{logger: newTxLogger(newTestLogger(&buf), tt.opts)}
+
+			if tt.wantLogs > 0 {
+				mockTx.EXPECT().Exec(gomock.Any(), "UPDATE users SET password = $1", "secret").Return(pgconn.CommandTag{}, nil)
+				_, err := p.Exec(tt.ctx, "UPDATE users SET password = $1", "secret")
+				assert.NoError(t, err)
+			} else {
+				p.logger.query(tt.ctx, "SELECT 1", nil, 0, nil)
+			}
+
+			logs := decodeLogs(t, &buf)
+			require.Len(t, logs, tt.wantLogs)
+			if tt.wantLogs == 0 {
+				return
+			}
+			assert.Equal(t, "pgxatomic: query", logs[0]["msg"])
+			assert.Equal(t, "UPDATE users SET password = $1", logs[0]["sql"])
+			assert.Equal(t, tt.wantArgs, logs[0]["args"])
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package pgxatomic
+
+import "time"
+
+// Metrics receives transaction lifecycle events from Runner. Name is the
+// transaction name set with WithTxName, empty if not set.
+type Metrics interface {
+	// TxStarted is called after transaction has begun.
+	TxStarted(name string)
+	// TxCommitted is called after successful commit with transaction duration
+	// and number of statements executed via Query, QueryRow and Exec.
+	TxCommitted(name string, d time.Duration, statements int)
+	// TxRolledBack is called after transaction is rolled back or commit failed.
+	TxRolledBack(name string, d time.Duration, statements int)
+	// TxRetried is called before failed transaction is retried.
+	TxRetried(name string)
+	// TxBeginFailed is called when transaction could not be started.
+	TxBeginFailed(name string)
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) TxStarted(string)                        {}
+func (noopMetrics) TxCommitted(string, time.Duration, int)  {}
+func (noopMetrics) TxRolledBack(string, time.Duration, int) {}
+func (noopMetrics) TxRetried(string)                        {}
+func (noopMetrics) TxBeginFailed(string)                    {}
@@ -0,0 +1,194 @@
+package pgxatomic
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrMutexLocked is returned by Mutex.Lock and Mutex.TryLock if mutex is
+// already held or being acquired through the same Mutex.
+var ErrMutexLocked = errors.New("pgxatomic: mutex already locked")
+
+const defaultKeepAlive = 5 * time.Second
+
+// Mutex is distributed mutex built on session level advisory lock held on
+// dedicated connection, it is suitable for leader election and singleton jobs.
+// Connection is checked periodically and Lost channel is closed if it dies,
+// since server releases the lock with it.
+type Mutex struct {
+	pool      Pool
+	key       AdvisoryKey
+	keepAlive time.Duration
+
+	mu        sync.Mutex
+	held      *mutexHold
+	acquiring bool
+	// lost is closed channel of hold lost since last acquisition
+	lost chan struct{}
+}
+
+type mutexHold struct {
+	lock *AdvisoryLock
+	lost chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// MutexOption configures Mutex.
+type MutexOption func(*Mutex)
+
+// WithKeepAlive sets interval of connection checks while mutex is held, 5s by default.
+func WithKeepAlive(d time.Duration) MutexOption {
+	return func(m *Mutex) {
+		m.keepAlive = d
+	}
+}
+
+func NewMutex(p *pgxpool.Pool, key AdvisoryKey, opts ...MutexOption) (*Mutex, error) {
+	pool, err := NewPool(p)
+	if err != nil {
+		return nil, err
+	}
+	m := &Mutex{
+		pool:      pool,
+		key:       key,
+		keepAlive: defaultKeepAlive,
+	}
+	for _, o := range opts {
+		o(m)
+	}
+	if m.keepAlive <= 0 {
+		return nil, errors.New("pgxatomic: keep-alive interval must be positive")
+	}
+	return m, nil
+}
+
+// Lock acquires mutex, waiting until other holders release it or ctx is
+// done. ErrMutexLocked is returned immediately if m is already held or
+// another Lock call on m is waiting.
+func (m *Mutex) Lock(ctx context.Context) error {
+	m.mu.Lock()
+	if m.held != nil || m.acquiring {
+		m.mu.Unlock()
+		return ErrMutexLocked
+	}
+	m.acquiring = true
+	m.mu.Unlock()
+
+	// m.mu is not held while waiting, so Unlock, Lost and TryLock do not block
+	lock, err := m.pool.LockAdvisory(ctx, m.key)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.acquiring = false
+	if err != nil {
+		return err
+	}
+	m.hold(lock)
+	return nil
+}
+
+// TryLock acquires mutex if it is available and reports whether it is acquired.
+func (m *Mutex) TryLock(ctx context.Context) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.held != nil || m.acquiring {
+		return false, ErrMutexLocked
+	}
+
+	lock, ok, err := m.pool.TryLockAdvisory(ctx, m.key)
+	if err != nil || !ok {
+		return false, err
+	}
+	m.hold(lock)
+	return true, nil
+}
+
+// Unlock releases mutex, ErrLockNotHeld is returned if it is not held or lost.
+func (m *Mutex) Unlock(ctx context.Context) error {
+	m.mu.Lock()
+	h := m.held
+	m.held = nil
+	m.mu.Unlock()
+
+	if h == nil {
+		return ErrLockNotHeld
+	}
+
+	close(h.stop)
+	<-h.done
+
+	return h.lock.Unlock(ctx)
+}
+
+// Lost returns channel closed when held mutex is lost because its connection
+// died. Channel is replaced on each acquisition and never closed by Unlock.
+// Closed channel is returned after loss until mutex is acquired again, nil is
+// returned if mutex is not held and was not lost.
+func (m *Mutex) Lost() <-chan struct{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.held == nil {
+		return m.lost
+	}
+	return m.held.lost
+}
+
+// hold must be called with m.mu held.
+func (m *Mutex) hold(lock *AdvisoryLock) {
+	h := &mutexHold{
+		lock: lock,
+		lost: make(chan struct{}),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	m.held = h
+	m.lost = nil
+	go m.watch(h)
+}
+
+// watch pings connection of h until it is stopped or ping fails.
+func (m *Mutex) watch(h *mutexHold) {
+	defer close(h.done)
+
+	t := time.NewTicker(m.keepAlive)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-h.stop:
+			return
+		case <-t.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), m.keepAlive)
+		err := h.lock.conn.Ping(ctx)
+		cancel()
+		if err == nil {
+			continue
+		}
+
+		h.lock.abandon(context.Background())
+		m.markLost(h)
+		return
+	}
+}
+
+// markLost closes lost channel of h and forgets h if it is still held.
+func (m *Mutex) markLost(h *mutexHold) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.held == h {
+		m.held = nil
+		m.lost = h.lost
+	}
+	close(h.lost)
+}
@@ -0,0 +1,133 @@
+package pgxatomic
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ysomad/pgxatomic/internal/pgtest"
+)
+
+func TestNewMutex(t *testing.T) {
+	_, err := NewMutex(nil, Int64Key(1))
+	assert.Error(t, err)
+}
+
+func TestMutex(t *testing.T) {
+	pool := pgtest.Pool(t)
+	ctx := context.Background()
+	key := StringKey("pgxatomic:test:mutex")
+
+	m1, err := NewMutex(pool, key)
+	require.NoError(t, err)
+	m2, err := NewMutex(pool, key)
+	require.NoError(t, err)
+
+	assert.Nil(t, m1.Lost())
+	assert.ErrorIs(t, m1.Unlock(ctx), ErrLockNotHeld)
+
+	require.NoError(t, m1.Lock(ctx))
+	assert.NotNil(t, m1.Lost())
+	assert.ErrorIs(t, m1.Lock(ctx), ErrMutexLocked)
+
+	ok, err := m2.TryLock(ctx)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	lockCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	assert.Error(t, m2.Lock(lockCtx))
+
+	require.NoError(t, m1.Unlock(ctx))
+
+	ok, err = m2.TryLock(ctx)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	require.NoError(t, m2.Unlock(ctx))
+}
+
+func TestMutex_Lost(t *testing.T) {
+	pool := pgtest.Pool(t)
+	ctx := context.Background()
+
+	m, err := NewMutex(pool, StringKey("pgxatomic:test:mutex_lost"), WithKeepAlive(50*time.Millisecond))
+	require.NoError(t, err)
+
+	require.NoError(t, m.Lock(ctx))
+	lost := m.Lost()
+
+	m.mu.Lock()
+	pid := m.held.lock.conn.Conn().PgConn().PID()
+	m.mu.Unlock()
+
+	_, err = pool.Exec(ctx, "SELECT pg_terminate_backend($1)", pid)
+	require.NoError(t, err)
+
+	select {
+	case <-lost:
+	case <-time.After(5 * time.Second):
+		t.Fatal("mutex loss is not detected")
+	}
+
+	assert.Equal(t, lost, m.Lost())
+	assert.ErrorIs(t, m.Unlock(ctx), ErrLockNotHeld)
+
+	ok, err := m.TryLock(ctx)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.NotEqual(t, lost, m.Lost())
+	require.NoError(t, m.Unlock(ctx))
+}
+
+func TestMutex_LostAfterLoss(t *testing.T) {
+	m := &Mutex{}
+	h := &mutexHold{lost: make(chan struct{})}
+	m.held = h
+
+	m.markLost(h)
+
+	// caller reading Lost after loss is detected must not block
+	select {
+	case <-m.Lost():
+	default:
+		t.Fatal("Lost must return closed channel after loss")
+	}
+	assert.Nil(t, m.held)
+}
+
+func TestMutex_LockWaiting(t *testing.T) {
+	pool := pgtest.Pool(t)
+	ctx := context.Background()
+	key := StringKey("pgxatomic:test:mutex_waiting")
+
+	m1, err := NewMutex(pool, key)
+	require.NoError(t, err)
+	m2, err := NewMutex(pool, key)
+	require.NoError(t, err)
+
+	require.NoError(t, m1.Lock(ctx))
+
+	locked := make(chan error)
+	go func() { locked <- m2.Lock(ctx) }()
+
+	require.Eventually(t, func() bool {
+		m2.mu.Lock()
+		defer m2.mu.Unlock()
+		return m2.acquiring
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// waiting Lock does not block other methods
+	assert.Nil(t, m2.Lost())
+	assert.ErrorIs(t, m2.Unlock(ctx), ErrLockNotHeld)
+	_, err = m2.TryLock(ctx)
+	assert.ErrorIs(t, err, ErrMutexLocked)
+	assert.ErrorIs(t, m2.Lock(ctx), ErrMutexLocked)
+
+	require.NoError(t, m1.Unlock(ctx))
+	require.NoError(t, <-locked)
+	assert.NotNil(t, m2.Lost())
+	require.NoError(t, m2.Unlock(ctx))
+}
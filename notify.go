@@ -0,0 +1,140 @@
+package pgxatomic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Notify sends notification with pg_notify using transaction from ctx, so it
+// is delivered to listeners only if transaction commits. ErrNoTx is returned
+// if there is no transaction in ctx.
+func Notify(ctx context.Context, channel, payload string) error {
+	tx := txFromContext(ctx)
+	if tx == nil {
+		return ErrNoTx
+	}
+	_, err := tx.Exec(ctx, "SELECT pg_notify($1, $2)", channel, payload)
+	return err
+}
+
+// NotificationHandler handles notification received by Listener.
+type NotificationHandler func(ctx context.Context, n *pgconn.Notification) error
+
+// Listener listens to channels on dedicated connection and dispatches
+// notifications to handlers, it reconnects and listens again if connection
+// is lost. Notifications sent while connection is lost are not delivered.
+type Listener struct {
+	pool           *pgxpool.Pool
+	reconnectDelay time.Duration
+	onError        func(error)
+
+	mu       sync.RWMutex
+	handlers map[string]NotificationHandler
+}
+
+// ListenerOption configures Listener.
+type ListenerOption func(*Listener)
+
+// WithReconnectDelay sets delay before reconnect after connection is lost, 1s by default.
+func WithReconnectDelay(d time.Duration) ListenerOption {
+	return func(l *Listener) {
+		l.reconnectDelay = d
+	}
+}
+
+// WithListenErrorHandler sets function called with connection and handler
+// errors, they are ignored by default.
+func WithListenErrorHandler(fn func(error)) ListenerOption {
+	return func(l *Listener) {
+		l.onError = fn
+	}
+}
+
+func NewListener(p *pgxpool.Pool, opts ...ListenerOption) (*Listener, error) {
+	if p == nil {
+		return nil, errors.New("pgxatomic: pool cannot be nil")
+	}
+	l := &Listener{
+		pool:           p,
+		reconnectDelay: time.Second,
+		onError:        func(error) {},
+		handlers:       make(map[string]NotificationHandler),
+	}
+	for _, o := range opts {
+		o(l)
+	}
+	return l, nil
+}
+
+// Handle registers handler of channel notifications, it must be called
+// before Listen.
+func (l *Listener) Handle(channel string, h NotificationHandler) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.handlers[channel] = h
+}
+
+// Listen blocks receiving notifications until ctx is done.
+func (l *Listener) Listen(ctx context.Context) error {
+	for {
+		err := l.listen(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		l.onError(err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(l.reconnectDelay):
+		}
+	}
+}
+
+func (l *Listener) listen(ctx context.Context) error {
+	pconn, err := l.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("pgxatomic: acquire listener conn: %w", err)
+	}
+	// connection is taken out of pool since it must not be reused while listening
+	conn := pconn.Hijack()
+	defer conn.Close(context.WithoutCancel(ctx))
+
+	l.mu.RLock()
+	channels := make([]string, 0, len(l.handlers))
+	for ch := range l.handlers {
+		channels = append(channels, ch)
+	}
+	l.mu.RUnlock()
+
+	for _, ch := range channels {
+		if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{ch}.Sanitize()); err != nil {
+			return fmt.Errorf("pgxatomic: listen %s: %w", ch, err)
+		}
+	}
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("pgxatomic: wait for notification: %w", err)
+		}
+
+		l.mu.RLock()
+		h, ok := l.handlers[n.Channel]
+		l.mu.RUnlock()
+
+		if !ok {
+			continue
+		}
+		if err := h(ctx, n); err != nil {
+			l.onError(fmt.Errorf("pgxatomic: handle %s notification: %w", n.Channel, err))
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package pgxatomic
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/ysomad/pgxatomic/internal/pgtest"
+)
+
+func TestNotify(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockTx := NewMockTx(ctrl)
+	mockTx.EXPECT().Exec(gomock.Any(), "SELECT pg_notify($1, $2)", "cache", "users:1").Return(pgconn.NewCommandTag("SELECT 1"), nil)
+
+	assert.NoError(t, Notify(WithTx(context.Background(), mockTx), "cache", "users:1"))
+	assert.ErrorIs(t, Notify(context.Background(), "cache", "users:1"), ErrNoTx)
+}
+
+func TestNewListener(t *testing.T) {
+	_, err := NewListener(nil)
+	assert.Error(t, err)
+}
+
+func TestListener(t *testing.T) {
+	pool := pgtest.Pool(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const channel = "pgxatomic_test_listener"
+
+	received := make(chan string, 10)
+	l, err := NewListener(pool, WithReconnectDelay(10*time.Millisecond))
+	require.NoError(t, err)
+	l.Handle(channel, func(ctx context.Context, n *pgconn.Notification) error {
+		received <- n.Payload
+		return nil
+	})
+
+	listenErr := make(chan error, 1)
+	go func() { listenErr <- l.Listen(ctx) }()
+
+	runner, err := NewRunner(pool, pgx.TxOptions{})
+	require.NoError(t, err)
+
+	notify := func(payload string, fail bool) {
+		err := runner.Run(ctx, func(ctx context.Context) error {
+			if err := Notify(ctx, channel, payload); err != nil {
+				return err
+			}
+			if fail {
+				return errTest
+			}
+			return nil
+		})
+		if fail {
+			require.ErrorIs(t, err, errTest)
+		} else {
+			require.NoError(t, err)
+		}
+	}
+
+	waitPayload := func(want string) {
+		t.Helper()
+		deadline := time.After(5 * time.Second)
+		// notify repeatedly until listener is connected
+		for {
+			select {
+			case got := <-received:
+				if got == want {
+					return
+				}
+			case <-time.After(50 * time.Millisecond):
+				notify(want, false)
+			case <-deadline:
+				t.Fatalf("notification %q is not received", want)
+			}
+		}
+	}
+
+	waitPayload("ready")
+
+	notify("rolled back", true)
+	notify("committed", false)
+	assert.Equal(t, "committed", <-received)
+
+	_, err = pool.Exec(ctx, `SELECT pg_terminate_backend(pid) FROM pg_stat_activity
+		WHERE query = 'LISTEN "`+channel+`"'`)
+	require.NoError(t, err)
+
+	waitPayload("reconnected")
+
+	cancel()
+	assert.ErrorIs(t, <-listenErr, context.Canceled)
+}
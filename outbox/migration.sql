@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS pgxatomic_outbox (
+    id              bigserial   PRIMARY KEY,
+    topic           text        NOT NULL,
+    key             text        NOT NULL,
+    payload         bytea       NOT NULL,
+    created_at      timestamptz NOT NULL DEFAULT now(),
+    attempts        integer     NOT NULL DEFAULT 0,
+    next_attempt_at timestamptz NOT NULL DEFAULT now(),
+    last_error      text,
+    delivered_at    timestamptz,
+    failed_at       timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS pgxatomic_outbox_pending_idx
+    ON pgxatomic_outbox (key, id)
+    WHERE delivered_at IS NULL AND failed_at IS NULL;
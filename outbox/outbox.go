@@ -0,0 +1,45 @@
+// Package outbox implements transactional outbox on top of pgxatomic.
+//
+// Messages are written with Put in the same transaction as business data
+// and delivered by Relay to user-provided Publisher after commit.
+// Migration must be applied before use.
+package outbox
+
+import (
+	"context"
+	_ "embed"
+	"time"
+
+	"github.com/ysomad/pgxatomic"
+)
+
+// Migration creates outbox table.
+//
+//go:embed migration.sql
+var Migration string
+
+// Message is outbox message.
+type Message struct {
+	ID        int64
+	Topic     string
+	Key       string
+	Payload   []byte
+	CreatedAt time.Time
+
+	// Attempts is number of failed delivery attempts.
+	Attempts int
+}
+
+// Put inserts message into outbox using transaction from ctx,
+// pgxatomic.ErrNoTx is returned if there is none. Messages with the same key
+// are delivered in order they are put.
+func Put(ctx context.Context, topic, key string, payload []byte) error {
+	tx := pgxatomic.TxFromContext(ctx)
+	if tx == nil {
+		return pgxatomic.ErrNoTx
+	}
+	_, err := pgxatomic.Exec(ctx, tx,
+		"INSERT INTO pgxatomic_outbox (topic, key, payload) VALUES ($1, $2, $3)",
+		topic, key, payload)
+	return err
+}
@@ -0,0 +1,120 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ysomad/pgxatomic"
+	"github.com/ysomad/pgxatomic/internal/pgtest"
+)
+
+type execTx struct {
+	pgx.Tx
+	sql  string
+	args []any
+}
+
+func (tx *execTx) Exec(_ context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	tx.sql, tx.args = sql, args
+	return pgconn.NewCommandTag("INSERT 0 1"), nil
+}
+
+func TestPut(t *testing.T) {
+	tx := &execTx{}
+	ctx := pgxatomic.WithTx(context.Background(), tx)
+
+	require.NoError(t, Put(ctx, "orders", "order-1", []byte(`{"id":1}`)))
+	assert.Equal(t, "INSERT INTO pgxatomic_outbox (topic, key, payload) VALUES ($1, $2, $3)", tx.sql)
+	assert.Equal(t, []any{"orders", "order-1", []byte(`{"id":1}`)}, tx.args)
+
+	assert.ErrorIs(t, Put(context.Background(), "orders", "order-1", nil), pgxatomic.ErrNoTx)
+}
+
+type errRunner struct{ err error }
+
+func (r errRunner) Run(context.Context, func(ctx context.Context) error, ...pgxatomic.RunOption) error {
+	return r.err
+}
+
+func TestRelay_OnError(t *testing.T) {
+	errMissingTable := errors.New(`relation "pgxatomic_outbox" does not exist`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var got []error
+	r, err := NewRelay(errRunner{err: errMissingTable}, PublisherFunc(func(context.Context, Message) error { return nil }), Config{
+		PollInterval: time.Millisecond,
+		OnError: func(err error) {
+			got = append(got, err)
+			if len(got) == 2 {
+				cancel()
+			}
+		},
+	})
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, r.Run(ctx), context.Canceled)
+	assert.Equal(t, []error{errMissingTable, errMissingTable}, got)
+}
+
+func TestRelay(t *testing.T) {
+	pool := pgtest.Pool(t)
+	ctx := context.Background()
+
+	_, err := pool.Exec(ctx, Migration)
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, "TRUNCATE pgxatomic_outbox")
+	require.NoError(t, err)
+
+	runner, err := pgxatomic.NewRunner(pool, pgx.TxOptions{})
+	require.NoError(t, err)
+
+	err = runner.Run(ctx, func(ctx context.Context) error {
+		for _, m := range []struct{ key, payload string }{
+			{"a", "a1"}, {"b", "b1"}, {"a", "a2"}, {"b", "b2"},
+		} {
+			if err := Put(ctx, "topic", m.key, []byte(m.payload)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	var published []string
+	failB := true
+	relay, err := NewRelay(runner, PublisherFunc(func(ctx context.Context, msg Message) error {
+		if msg.Key == "b" && failB {
+			failB = false
+			return assert.AnError
+		}
+		published = append(published, string(msg.Payload))
+		return nil
+	}), Config{Backoff: func(int) time.Duration { return 0 }})
+	require.NoError(t, err)
+
+	for range 4 {
+		_, err := relay.RelayBatch(ctx)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []string{"a1", "a2", "b1", "b2"}, published)
+
+	var attempts int
+	var lastErr string
+	err = pool.QueryRow(ctx, "SELECT attempts, last_error FROM pgxatomic_outbox WHERE payload = 'b1'").Scan(&attempts, &lastErr)
+	require.NoError(t, err)
+	assert.Equal(t, 1, attempts)
+	assert.Equal(t, assert.AnError.Error(), lastErr)
+
+	n, err := relay.RelayBatch(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
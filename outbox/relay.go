@@ -0,0 +1,172 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/ysomad/pgxatomic"
+)
+
+// Publisher delivers message to broker, message is marked as delivered if
+// Publish returns nil and retried later otherwise.
+type Publisher interface {
+	Publish(ctx context.Context, msg Message) error
+}
+
+// PublisherFunc is function implementing Publisher.
+type PublisherFunc func(ctx context.Context, msg Message) error
+
+func (f PublisherFunc) Publish(ctx context.Context, msg Message) error { return f(ctx, msg) }
+
+// Config configures Relay, zero values are replaced with defaults.
+type Config struct {
+	// BatchSize is max number of messages claimed in single transaction, 100 by default.
+	BatchSize int
+
+	// PollInterval is delay between polls when outbox is empty, 1s by default.
+	PollInterval time.Duration
+
+	// Unordered allows delivery of messages with the same key out of order,
+	// so failed message does not block following ones.
+	Unordered bool
+
+	// MaxAttempts is number of delivery attempts after which message is
+	// marked as failed and no longer delivered, unlimited if zero.
+	MaxAttempts int
+
+	// Backoff returns delay before next delivery attempt after attempt
+	// failed, pgxatomic.ExponentialBackoff is used by default.
+	Backoff func(attempt int) time.Duration
+
+	// OnError is called with errors of relaying batch, batch is retried
+	// after PollInterval.
+	OnError func(error)
+}
+
+// Relay polls outbox with FOR UPDATE SKIP LOCKED and publishes messages,
+// multiple relays may run concurrently.
+type Relay struct {
+	runner pgxatomic.TxRunner
+	pub    Publisher
+	cfg    Config
+}
+
+func NewRelay(runner pgxatomic.TxRunner, pub Publisher, cfg Config) (*Relay, error) {
+	if pub == nil {
+		return nil, errors.New("outbox: publisher cannot be nil")
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.Backoff == nil {
+		cfg.Backoff = pgxatomic.ExponentialBackoff
+	}
+	if cfg.OnError == nil {
+		cfg.OnError = func(error) {}
+	}
+	return &Relay{runner: runner, pub: pub, cfg: cfg}, nil
+}
+
+// Run relays messages until ctx is done.
+func (r *Relay) Run(ctx context.Context) error {
+	for {
+		n, err := r.RelayBatch(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			r.cfg.OnError(err)
+		}
+		if err != nil || n < r.cfg.BatchSize {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(r.cfg.PollInterval):
+			}
+		}
+	}
+}
+
+const claimSQL = `SELECT id, topic, key, payload, created_at, attempts
+FROM pgxatomic_outbox o
+WHERE delivered_at IS NULL AND failed_at IS NULL AND next_attempt_at <= now()`
+
+// claimOrderedSQL claims only the oldest pending message of each key, so
+// following messages wait until it is delivered or failed.
+const claimOrderedSQL = claimSQL + `
+AND NOT EXISTS (
+	SELECT 1 FROM pgxatomic_outbox p
+	WHERE p.key = o.key AND p.id < o.id AND p.delivered_at IS NULL AND p.failed_at IS NULL
+)`
+
+const claimSuffix = `
+ORDER BY id
+LIMIT $1
+FOR UPDATE SKIP LOCKED`
+
+// RelayBatch claims single batch of messages, publishes them and returns
+// number of claimed messages.
+func (r *Relay) RelayBatch(ctx context.Context) (int, error) {
+	var n int
+	err := r.runner.Run(ctx, func(ctx context.Context) error {
+		msgs, err := r.claim(ctx)
+		if err != nil {
+			return err
+		}
+		n = len(msgs)
+
+		for _, msg := range msgs {
+			if err := r.publish(ctx, msg); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, pgxatomic.WithTxName("outbox_relay"))
+	return n, err
+}
+
+func (r *Relay) claim(ctx context.Context) ([]Message, error) {
+	sql := claimOrderedSQL
+	if r.cfg.Unordered {
+		sql = claimSQL
+	}
+
+	rows, err := pgxatomic.Query(ctx, pgxatomic.TxFromContext(ctx), sql+claimSuffix, r.cfg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Message, error) {
+		var m Message
+		err := row.Scan(&m.ID, &m.Topic, &m.Key, &m.Payload, &m.CreatedAt, &m.Attempts)
+		return m, err
+	})
+}
+
+func (r *Relay) publish(ctx context.Context, msg Message) error {
+	tx := pgxatomic.TxFromContext(ctx)
+
+	pubErr := r.pub.Publish(ctx, msg)
+	if pubErr == nil {
+		_, err := pgxatomic.Exec(ctx, tx, "UPDATE pgxatomic_outbox SET delivered_at = now() WHERE id = $1", msg.ID)
+		return err
+	}
+
+	attempts := msg.Attempts + 1
+	if r.cfg.MaxAttempts > 0 && attempts >= r.cfg.MaxAttempts {
+		_, err := pgxatomic.Exec(ctx, tx,
+			"UPDATE pgxatomic_outbox SET attempts = $2, last_error = $3, failed_at = now() WHERE id = $1",
+			msg.ID, attempts, pubErr.Error())
+		return err
+	}
+
+	_, err := pgxatomic.Exec(ctx, tx,
+		"UPDATE pgxatomic_outbox SET attempts = $2, last_error = $3, next_attempt_at = now() + $4::interval WHERE id = $1",
+		msg.ID, attempts, pubErr.Error(), r.cfg.Backoff(attempts))
+	return err
+}
@@ -0,0 +1,181 @@
+// Package pgerr classifies PostgreSQL errors returned by pgx.
+package pgerr
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// SQLSTATE codes of classified errors.
+const (
+	CodeNotNullViolation       = "23502"
+	CodeForeignKeyViolation    = "23503"
+	CodeUniqueViolation        = "23505"
+	CodeCheckViolation         = "23514"
+	CodeReadOnlySQLTransaction = "25006"
+	CodeSerializationFailure   = "40001"
+	CodeDeadlockDetected       = "40P01"
+	CodeLockNotAvailable       = "55P03"
+	CodeQueryCanceled          = "57014"
+)
+
+// Kind is class of PostgreSQL error.
+type Kind int
+
+const (
+	KindUnknown Kind = iota
+	KindUniqueViolation
+	KindForeignKeyViolation
+	KindCheckViolation
+	KindNotNullViolation
+	KindSerializationFailure
+	KindDeadlock
+	KindLockNotAvailable
+	KindQueryCanceled
+	KindReadOnlyTransaction
+)
+
+var kindNames = map[Kind]string{
+	KindUnknown:              "unknown",
+	KindUniqueViolation:      "unique_violation",
+	KindForeignKeyViolation:  "foreign_key_violation",
+	KindCheckViolation:       "check_violation",
+	KindNotNullViolation:     "not_null_violation",
+	KindSerializationFailure: "serialization_failure",
+	KindDeadlock:             "deadlock_detected",
+	KindLockNotAvailable:     "lock_not_available",
+	KindQueryCanceled:        "query_canceled",
+	KindReadOnlyTransaction:  "read_only_sql_transaction",
+}
+
+func (k Kind) String() string {
+	if name, ok := kindNames[k]; ok {
+		return name
+	}
+	return kindNames[KindUnknown]
+}
+
+// Retryable reports whether transaction failed with error of kind k may
+// succeed if retried from the beginning.
+func (k Kind) Retryable() bool {
+	return k == KindSerializationFailure || k == KindDeadlock
+}
+
+// IsConstraintViolation reports whether k is integrity constraint violation.
+func (k Kind) IsConstraintViolation() bool {
+	switch k {
+	case KindUniqueViolation, KindForeignKeyViolation, KindCheckViolation, KindNotNullViolation:
+		return true
+	default:
+		return false
+	}
+}
+
+var codeKinds = map[string]Kind{
+	CodeUniqueViolation:        KindUniqueViolation,
+	CodeForeignKeyViolation:    KindForeignKeyViolation,
+	CodeCheckViolation:         KindCheckViolation,
+	CodeNotNullViolation:       KindNotNullViolation,
+	CodeSerializationFailure:   KindSerializationFailure,
+	CodeDeadlockDetected:       KindDeadlock,
+	CodeLockNotAvailable:       KindLockNotAvailable,
+	CodeQueryCanceled:          KindQueryCanceled,
+	CodeReadOnlySQLTransaction: KindReadOnlyTransaction,
+}
+
+// Error is typed wrapper of *pgconn.PgError.
+type Error struct {
+	Kind Kind
+
+	// Constraint, Table and Column are set for constraint violations if
+	// reported by server.
+	Constraint string
+	Table      string
+	Column     string
+
+	PgErr *pgconn.PgError
+	err   error
+}
+
+func (e *Error) Error() string { return e.err.Error() }
+
+func (e *Error) Unwrap() error { return e.err }
+
+// As returns *Error describing first *pgconn.PgError in err chain,
+// false is returned if there is none.
+func As(err error) (*Error, bool) {
+	var e *Error
+	if errors.As(err, &e) {
+		return e, true
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return nil, false
+	}
+
+	return &Error{
+		Kind:       codeKinds[pgErr.Code],
+		Constraint: pgErr.ConstraintName,
+		Table:      pgErr.TableName,
+		Column:     pgErr.ColumnName,
+		PgErr:      pgErr,
+		err:        err,
+	}, true
+}
+
+// Wrap returns err wrapped in *Error if it contains *pgconn.PgError,
+// err is returned as is otherwise.
+func Wrap(err error) error {
+	if e, ok := As(err); ok {
+		return e
+	}
+	return err
+}
+
+// Classify returns kind of PostgreSQL error in err chain.
+func Classify(err error) Kind {
+	if e, ok := As(err); ok {
+		return e.Kind
+	}
+	return KindUnknown
+}
+
+// Code returns SQLSTATE code of PostgreSQL error in err chain or empty string.
+func Code(err error) string {
+	if e, ok := As(err); ok {
+		return e.PgErr.Code
+	}
+	return ""
+}
+
+// Constraint returns name of violated constraint or empty string.
+func Constraint(err error) string {
+	if e, ok := As(err); ok {
+		return e.Constraint
+	}
+	return ""
+}
+
+func IsUniqueViolation(err error) bool { return Classify(err) == KindUniqueViolation }
+
+func IsForeignKeyViolation(err error) bool { return Classify(err) == KindForeignKeyViolation }
+
+func IsCheckViolation(err error) bool { return Classify(err) == KindCheckViolation }
+
+func IsNotNullViolation(err error) bool { return Classify(err) == KindNotNullViolation }
+
+func IsSerializationFailure(err error) bool { return Classify(err) == KindSerializationFailure }
+
+func IsDeadlock(err error) bool { return Classify(err) == KindDeadlock }
+
+func IsLockNotAvailable(err error) bool { return Classify(err) == KindLockNotAvailable }
+
+func IsQueryCanceled(err error) bool { return Classify(err) == KindQueryCanceled }
+
+func IsReadOnlyTransaction(err error) bool { return Classify(err) == KindReadOnlyTransaction }
+
+// IsRetryable reports whether transaction failed with err may succeed if
+// retried from the beginning, it is used by pgxatomic.Runner by default.
+func IsRetryable(err error) bool { return Classify(err).Retryable() }
@@ -0,0 +1,78 @@
+package pgerr
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		want      Kind
+		retryable bool
+		is        func(error) bool
+	}{
+		{name: "nil", err: nil, want: KindUnknown},
+		{name: "not pg error", err: errors.New("boom"), want: KindUnknown},
+		{name: "unknown code", err: &pgconn.PgError{Code: "42P01"}, want: KindUnknown},
+		{name: "unique", err: &pgconn.PgError{Code: "23505"}, want: KindUniqueViolation, is: IsUniqueViolation},
+		{name: "foreign key", err: &pgconn.PgError{Code: "23503"}, want: KindForeignKeyViolation, is: IsForeignKeyViolation},
+		{name: "check", err: &pgconn.PgError{Code: "23514"}, want: KindCheckViolation, is: IsCheckViolation},
+		{name: "not null", err: &pgconn.PgError{Code: "23502"}, want: KindNotNullViolation, is: IsNotNullViolation},
+		{name: "serialization", err: &pgconn.PgError{Code: "40001"}, want: KindSerializationFailure, retryable: true, is: IsSerializationFailure},
+		{name: "deadlock", err: &pgconn.PgError{Code: "40P01"}, want: KindDeadlock, retryable: true, is: IsDeadlock},
+		{name: "lock not available", err: &pgconn.PgError{Code: "55P03"}, want: KindLockNotAvailable, is: IsLockNotAvailable},
+		{name: "query canceled", err: &pgconn.PgError{Code: "57014"}, want: KindQueryCanceled, is: IsQueryCanceled},
+		{name: "read only", err: &pgconn.PgError{Code: "25006"}, want: KindReadOnlyTransaction, is: IsReadOnlyTransaction},
+		{name: "wrapped", err: fmt.Errorf("insert order: %w", &pgconn.PgError{Code: "40001"}), want: KindSerializationFailure, retryable: true, is: IsSerializationFailure},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Classify(tt.err))
+			assert.Equal(t, tt.retryable, IsRetryable(tt.err))
+			if tt.is != nil {
+				assert.True(t, tt.is(tt.err))
+			}
+		})
+	}
+}
+
+func TestAs(t *testing.T) {
+	pgErr := &pgconn.PgError{
+		Code:           CodeUniqueViolation,
+		Message:        "duplicate key value violates unique constraint",
+		ConstraintName: "users_email_key",
+		TableName:      "users",
+	}
+	err := fmt.Errorf("create user: %w", pgErr)
+
+	e, ok := As(err)
+	assert.True(t, ok)
+	assert.Equal(t, KindUniqueViolation, e.Kind)
+	assert.Equal(t, "users_email_key", e.Constraint)
+	assert.Equal(t, "users", e.Table)
+	assert.Same(t, pgErr, e.PgErr)
+	assert.True(t, e.Kind.IsConstraintViolation())
+	assert.Equal(t, "users_email_key", Constraint(err))
+	assert.Equal(t, CodeUniqueViolation, Code(err))
+
+	wrapped := Wrap(err)
+	assert.Equal(t, err.Error(), wrapped.Error())
+	assert.ErrorIs(t, wrapped, pgErr)
+
+	var typed *Error
+	assert.ErrorAs(t, fmt.Errorf("handler: %w", wrapped), &typed)
+	assert.Same(t, wrapped, error(typed))
+
+	_, ok = As(errors.New("boom"))
+	assert.False(t, ok)
+	assert.Equal(t, "", Code(nil))
+	assert.Equal(t, "unique_violation", KindUniqueViolation.String())
+	assert.Equal(t, "unknown", Kind(100).String())
+}
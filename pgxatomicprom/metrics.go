@@ -0,0 +1,128 @@
+// Package pgxatomicprom implements pgxatomic.Metrics with Prometheus client.
+package pgxatomicprom
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ysomad/pgxatomic"
+)
+
+var _ pgxatomic.Metrics = (*Metrics)(nil)
+
+// Options configures metric names and histogram buckets.
+type Options struct {
+	Namespace string
+	Subsystem string
+
+	// DurationBuckets in seconds, prometheus.DefBuckets is used if empty.
+	DurationBuckets []float64
+
+	// StatementBuckets of statements per transaction histogram.
+	StatementBuckets []float64
+}
+
+var defaultStatementBuckets = []float64{1, 2, 5, 10, 25, 50, 100, 250}
+
+// Metrics collects transaction metrics labelled by transaction name.
+// It must be registered in prometheus.Registerer to be exported.
+type Metrics struct {
+	started     *prometheus.CounterVec
+	committed   *prometheus.CounterVec
+	rolledBack  *prometheus.CounterVec
+	retried     *prometheus.CounterVec
+	beginFailed *prometheus.CounterVec
+	duration    *prometheus.HistogramVec
+	statements  *prometheus.HistogramVec
+}
+
+func New(opts Options) *Metrics {
+	if len(opts.DurationBuckets) == 0 {
+		opts.DurationBuckets = prometheus.DefBuckets
+	}
+	if len(opts.StatementBuckets) == 0 {
+		opts.StatementBuckets = defaultStatementBuckets
+	}
+
+	counter := func(name, help string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: opts.Namespace,
+			Subsystem: opts.Subsystem,
+			Name:      name,
+			Help:      help,
+		}, []string{"name"})
+	}
+
+	return &Metrics{
+		started:     counter("tx_started_total", "Number of started transactions."),
+		committed:   counter("tx_committed_total", "Number of committed transactions."),
+		rolledBack:  counter("tx_rolled_back_total", "Number of rolled back transactions."),
+		retried:     counter("tx_retried_total", "Number of retried transactions."),
+		beginFailed: counter("tx_begin_failed_total", "Number of transactions failed to begin."),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: opts.Namespace,
+			Subsystem: opts.Subsystem,
+			Name:      "tx_duration_seconds",
+			Help:      "Duration of transactions from begin to commit or rollback.",
+			Buckets:   opts.DurationBuckets,
+		}, []string{"name", "outcome"}),
+		statements: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: opts.Namespace,
+			Subsystem: opts.Subsystem,
+			Name:      "tx_statements",
+			Help:      "Number of statements executed per transaction.",
+			Buckets:   opts.StatementBuckets,
+		}, []string{"name"}),
+	}
+}
+
+func (m *Metrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		m.started,
+		m.committed,
+		m.rolledBack,
+		m.retried,
+		m.beginFailed,
+		m.duration,
+		m.statements,
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range m.collectors() {
+		c.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range m.collectors() {
+		c.Collect(ch)
+	}
+}
+
+func (m *Metrics) TxStarted(name string) {
+	m.started.WithLabelValues(name).Inc()
+}
+
+func (m *Metrics) TxCommitted(name string, d time.Duration, statements int) {
+	m.committed.WithLabelValues(name).Inc()
+	m.duration.WithLabelValues(name, "commit").Observe(d.Seconds())
+	m.statements.WithLabelValues(name).Observe(float64(statements))
+}
+
+func (m *Metrics) TxRolledBack(name string, d time.Duration, statements int) {
+	m.rolledBack.WithLabelValues(name).Inc()
+	m.duration.WithLabelValues(name, "rollback").Observe(d.Seconds())
+	m.statements.WithLabelValues(name).Observe(float64(statements))
+}
+
+func (m *Metrics) TxRetried(name string) {
+	m.retried.WithLabelValues(name).Inc()
+}
+
+func (m *Metrics) TxBeginFailed(name string) {
+	m.beginFailed.WithLabelValues(name).Inc()
+}
@@ -0,0 +1,50 @@
+package pgxatomicprom
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := New(Options{Namespace: "app"})
+	require.NoError(t, reg.Register(m))
+
+	m.TxStarted("create_order")
+	m.TxStarted("create_order")
+	m.TxCommitted("create_order", 10*time.Millisecond, 3)
+	m.TxRetried("create_order")
+	m.TxRolledBack("create_order", 20*time.Millisecond, 1)
+	m.TxBeginFailed("withdraw")
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.started.WithLabelValues("create_order")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.committed.WithLabelValues("create_order")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.rolledBack.WithLabelValues("create_order")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.retried.WithLabelValues("create_order")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.beginFailed.WithLabelValues("withdraw")))
+
+	err := testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP app_tx_statements Number of statements executed per transaction.
+# TYPE app_tx_statements histogram
+app_tx_statements_bucket{name="create_order",le="1"} 1
+app_tx_statements_bucket{name="create_order",le="2"} 1
+app_tx_statements_bucket{name="create_order",le="5"} 2
+app_tx_statements_bucket{name="create_order",le="10"} 2
+app_tx_statements_bucket{name="create_order",le="25"} 2
+app_tx_statements_bucket{name="create_order",le="50"} 2
+app_tx_statements_bucket{name="create_order",le="100"} 2
+app_tx_statements_bucket{name="create_order",le="250"} 2
+app_tx_statements_bucket{name="create_order",le="+Inf"} 2
+app_tx_statements_sum{name="create_order"} 4
+app_tx_statements_count{name="create_order"} 2
+`), "app_tx_statements")
+	assert.NoError(t, err)
+
+	assert.Equal(t, 2, testutil.CollectAndCount(m.duration))
+}
@@ -0,0 +1,42 @@
+package pgxatomictest
+
+import (
+	"slices"
+	"testing"
+)
+
+// Stater is implemented by RecordingTx, Run and FakeRunner.
+type Stater interface {
+	State() State
+}
+
+// AssertCommitted reports test error if transaction is not committed.
+func AssertCommitted(t testing.TB, tx Stater) bool {
+	t.Helper()
+	return assertState(t, tx, Committed)
+}
+
+// AssertRolledBack reports test error if transaction is not rolled back.
+func AssertRolledBack(t testing.TB, tx Stater) bool {
+	t.Helper()
+	return assertState(t, tx, RolledBack)
+}
+
+func assertState(t testing.TB, tx Stater, want State) bool {
+	t.Helper()
+	if got := tx.State(); got != want {
+		t.Errorf("pgxatomictest: transaction is %s, want %s", got, want)
+		return false
+	}
+	return true
+}
+
+// AssertExecuted reports test error if statement with sql is not executed in tx.
+func AssertExecuted(t testing.TB, tx *RecordingTx, sql string) bool {
+	t.Helper()
+	if !slices.Contains(tx.SQL(), sql) {
+		t.Errorf("pgxatomictest: statement %q is not executed, executed: %q", sql, tx.SQL())
+		return false
+	}
+	return true
+}
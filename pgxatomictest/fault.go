@@ -0,0 +1,290 @@
+package pgxatomictest
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/ysomad/pgxatomic"
+)
+
+// FaultOp is operation faults are injected into.
+type FaultOp int
+
+const (
+	// FaultBegin is BeginTx of wrapped TxStarter and savepoint Begin.
+	FaultBegin FaultOp = iota
+	// FaultQuery is Exec, Query, QueryRow and SendBatch of transaction.
+	FaultQuery
+	// FaultCommit is Commit of transaction or savepoint release.
+	FaultCommit
+	// FaultRollback is Rollback of transaction or savepoint.
+	FaultRollback
+)
+
+func (op FaultOp) String() string {
+	switch op {
+	case FaultBegin:
+		return "begin"
+	case FaultQuery:
+		return "query"
+	case FaultCommit:
+		return "commit"
+	case FaultRollback:
+		return "rollback"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrConnDropped is injected by ConnDrop, transaction returns it from every
+// following call as if connection was closed.
+var ErrConnDropped = errors.New("pgxatomictest: injected connection drop")
+
+// FaultRule injects Err or Delay into Op. Rule fires at Nth call of Op if Nth
+// is positive and with Probability otherwise.
+type FaultRule struct {
+	Op          FaultOp
+	Nth         int
+	Probability float64
+
+	// Err is returned instead of calling operation, operation is called after
+	// Delay if nil.
+	Err   error
+	Delay time.Duration
+}
+
+// BeginFailure fails begin with probability p.
+func BeginFailure(p float64) FaultRule {
+	return FaultRule{Op: FaultBegin, Probability: p, Err: errors.New("pgxatomictest: injected begin failure")}
+}
+
+// SerializationFailureOnCommit fails commit with serialization failure
+// with probability p.
+func SerializationFailureOnCommit(p float64) FaultRule {
+	return FaultRule{Op: FaultCommit, Probability: p, Err: &pgconn.PgError{
+		Severity: "ERROR",
+		Code:     "40001",
+		Message:  "could not serialize access due to concurrent update",
+	}}
+}
+
+// ConnDrop drops connection in query with probability p.
+func ConnDrop(p float64) FaultRule {
+	return FaultRule{Op: FaultQuery, Probability: p, Err: ErrConnDropped}
+}
+
+// SlowCommit delays commit by d with probability p.
+func SlowCommit(p float64, d time.Duration) FaultRule {
+	return FaultRule{Op: FaultCommit, Probability: p, Delay: d}
+}
+
+// At makes rule fire at nth call of its operation only.
+func (r FaultRule) At(n int) FaultRule {
+	r.Nth = n
+	r.Probability = 0
+	return r
+}
+
+// Injection is fault injected by Faults.
+type Injection struct {
+	Op FaultOp
+	// Call is number of call of Op, starting from 1.
+	Call  int
+	Err   error
+	Delay time.Duration
+}
+
+// Faults injects faults into transactions by rules, random decisions are
+// drawn from generator seeded with seed so sequential test reproduces the
+// same failures.
+type Faults struct {
+	rules []FaultRule
+
+	mu       sync.Mutex
+	rnd      *rand.Rand
+	calls    map[FaultOp]int
+	injected []Injection
+}
+
+func NewFaults(seed uint64, rules ...FaultRule) *Faults {
+	return &Faults{
+		rules: rules,
+		rnd:   rand.New(rand.NewPCG(seed, seed)),
+		calls: make(map[FaultOp]int),
+	}
+}
+
+// Injected returns injected faults in order.
+func (f *Faults) Injected() []Injection {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Injection(nil), f.injected...)
+}
+
+// Starter wraps db so its transactions are subject to faults.
+func (f *Faults) Starter(db pgxatomic.TxStarter) pgxatomic.TxStarter {
+	return &faultStarter{db: db, f: f}
+}
+
+// Tx wraps tx so it is subject to faults.
+func (f *Faults) Tx(tx pgx.Tx) pgx.Tx {
+	return &faultTx{Tx: tx, f: f}
+}
+
+// inject returns error to be returned from op, it waits for injected delay first.
+func (f *Faults) inject(ctx context.Context, op FaultOp) error {
+	f.mu.Lock()
+	f.calls[op]++
+	call := f.calls[op]
+
+	var fired *FaultRule
+	for i := range f.rules {
+		r := &f.rules[i]
+		if r.Op != op {
+			continue
+		}
+
+		// draw for every probabilistic rule to keep sequence independent
+		// of which rule fired
+		hit := false
+		if r.Nth > 0 {
+			hit = r.Nth == call
+		} else if r.Probability > 0 {
+			hit = f.rnd.Float64() < r.Probability
+		}
+		if hit && fired == nil {
+			fired = r
+		}
+	}
+	if fired != nil {
+		f.injected = append(f.injected, Injection{Op: op, Call: call, Err: fired.Err, Delay: fired.Delay})
+	}
+	f.mu.Unlock()
+
+	if fired == nil {
+		return nil
+	}
+
+	if fired.Delay > 0 {
+		t := time.NewTimer(fired.Delay)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return fired.Err
+}
+
+type faultStarter struct {
+	db pgxatomic.TxStarter
+	f  *Faults
+}
+
+func (s *faultStarter) BeginTx(ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error) {
+	if err := s.f.inject(ctx, FaultBegin); err != nil {
+		return nil, err
+	}
+	tx, err := s.db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return s.f.Tx(tx), nil
+}
+
+type faultTx struct {
+	pgx.Tx
+	f *Faults
+
+	mu      sync.Mutex
+	dropped bool
+}
+
+// fault injects fault into op and marks transaction dropped on ErrConnDropped.
+func (tx *faultTx) fault(ctx context.Context, op FaultOp) error {
+	tx.mu.Lock()
+	dropped := tx.dropped
+	tx.mu.Unlock()
+	if dropped {
+		return ErrConnDropped
+	}
+
+	err := tx.f.inject(ctx, op)
+	if errors.Is(err, ErrConnDropped) {
+		tx.mu.Lock()
+		tx.dropped = true
+		tx.mu.Unlock()
+	}
+	return err
+}
+
+func (tx *faultTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	if err := tx.fault(ctx, FaultBegin); err != nil {
+		return nil, err
+	}
+	sp, err := tx.Tx.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return tx.f.Tx(sp), nil
+}
+
+func (tx *faultTx) Commit(ctx context.Context) error {
+	if err := tx.fault(ctx, FaultCommit); err != nil {
+		// commit failure ends transaction as server rolls it back
+		_ = tx.Tx.Rollback(ctx)
+		return err
+	}
+	return tx.Tx.Commit(ctx)
+}
+
+func (tx *faultTx) Rollback(ctx context.Context) error {
+	if err := tx.fault(ctx, FaultRollback); err != nil {
+		_ = tx.Tx.Rollback(ctx)
+		return err
+	}
+	return tx.Tx.Rollback(ctx)
+}
+
+func (tx *faultTx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	if err := tx.fault(ctx, FaultQuery); err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	return tx.Tx.Exec(ctx, sql, args...)
+}
+
+func (tx *faultTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	if err := tx.fault(ctx, FaultQuery); err != nil {
+		return nil, err
+	}
+	return tx.Tx.Query(ctx, sql, args...)
+}
+
+func (tx *faultTx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	if err := tx.fault(ctx, FaultQuery); err != nil {
+		return errRow{err: err}
+	}
+	return tx.Tx.QueryRow(ctx, sql, args...)
+}
+
+func (tx *faultTx) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	if err := tx.fault(ctx, FaultQuery); err != nil {
+		return errBatchResults{err: err}
+	}
+	return tx.Tx.SendBatch(ctx, b)
+}
+
+type errBatchResults struct{ err error }
+
+func (r errBatchResults) Exec() (pgconn.CommandTag, error) { return pgconn.CommandTag{}, r.err }
+func (r errBatchResults) Query() (pgx.Rows, error)         { return nil, r.err }
+func (r errBatchResults) QueryRow() pgx.Row                { return errRow{err: r.err} }
+func (r errBatchResults) Close() error                     { return r.err }
@@ -0,0 +1,116 @@
+package pgxatomictest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ysomad/pgxatomic"
+	"github.com/ysomad/pgxatomic/pgerr"
+)
+
+// recordingStarter starts new RecordingTx for each transaction.
+type recordingStarter struct {
+	txs []*RecordingTx
+}
+
+func (s *recordingStarter) BeginTx(context.Context, pgx.TxOptions) (pgx.Tx, error) {
+	tx := NewRecordingTx()
+	s.txs = append(s.txs, tx)
+	return tx, nil
+}
+
+func TestFaults_Seed(t *testing.T) {
+	run := func(seed uint64) []Injection {
+		f := NewFaults(seed, BeginFailure(0.3))
+		db := f.Starter(&recordingStarter{})
+		for range 100 {
+			if tx, err := db.BeginTx(context.Background(), pgx.TxOptions{}); err == nil {
+				_ = tx.Rollback(context.Background())
+			}
+		}
+		return f.Injected()
+	}
+
+	first := run(42)
+	assert.NotEmpty(t, first)
+	assert.Less(t, len(first), 100)
+	assert.Equal(t, first, run(42))
+	assert.NotEqual(t, first, run(43))
+}
+
+func TestFaults_SerializationFailureOnCommit(t *testing.T) {
+	db := &recordingStarter{}
+	f := NewFaults(1, SerializationFailureOnCommit(1).At(1))
+
+	runner, err := pgxatomic.NewRunner(f.Starter(db), pgx.TxOptions{}, pgxatomic.WithRetry(2))
+	require.NoError(t, err)
+
+	var attempts int
+	err = runner.Run(context.Background(), func(ctx context.Context) error {
+		attempts++
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+	require.Len(t, db.txs, 2)
+	AssertRolledBack(t, db.txs[0])
+	AssertCommitted(t, db.txs[1])
+	assert.Equal(t, []Injection{{Op: FaultCommit, Call: 1, Err: f.rules[0].Err}}, f.Injected())
+}
+
+func TestFaults_ConnDrop(t *testing.T) {
+	db := &recordingStarter{}
+	f := NewFaults(1, ConnDrop(1).At(2))
+
+	runner, err := pgxatomic.NewRunner(f.Starter(db), pgx.TxOptions{})
+	require.NoError(t, err)
+
+	err = runner.Run(context.Background(), func(ctx context.Context) error {
+		for range 3 {
+			if _, err := pgxatomic.Exec(ctx, nil, "UPDATE users SET name = 'John'"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	var txErr *pgxatomic.TxError
+	require.ErrorAs(t, err, &txErr)
+	assert.ErrorIs(t, txErr.Cause, ErrConnDropped)
+	assert.ErrorIs(t, txErr.RollbackErr, ErrConnDropped)
+	AssertRolledBack(t, db.txs[0])
+	assert.Equal(t, []string{"UPDATE users SET name = 'John'", "ROLLBACK"}, db.txs[0].SQL())
+}
+
+func TestFaults_BeginFailure(t *testing.T) {
+	f := NewFaults(1, BeginFailure(1))
+
+	runner, err := pgxatomic.NewRunner(f.Starter(&recordingStarter{}), pgx.TxOptions{})
+	require.NoError(t, err)
+
+	err = runner.Run(context.Background(), func(ctx context.Context) error {
+		t.Fatal("txFunc must not be called")
+		return nil
+	})
+	assert.ErrorContains(t, err, "injected begin failure")
+	assert.False(t, pgerr.IsRetryable(err))
+}
+
+func TestFaults_SlowCommit(t *testing.T) {
+	db := &recordingStarter{}
+	f := NewFaults(1, SlowCommit(1, 20*time.Millisecond))
+
+	runner, err := pgxatomic.NewRunner(f.Starter(db), pgx.TxOptions{})
+	require.NoError(t, err)
+
+	start := time.Now()
+	err = runner.Run(context.Background(), func(ctx context.Context) error { return nil })
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	AssertCommitted(t, db.txs[0])
+}
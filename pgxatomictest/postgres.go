@@ -0,0 +1,34 @@
+package pgxatomictest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/ysomad/pgxatomic/internal/pgtest"
+)
+
+// PostgresOption configures server started by StartPostgres.
+type PostgresOption = pgtest.Option
+
+// WithSetting sets server configuration parameter passed with -c.
+func WithSetting(name, value string) PostgresOption {
+	return pgtest.WithSetting(name, value)
+}
+
+// WithStartTimeout sets how long StartPostgres waits for server to accept
+// connections, 30 seconds by default.
+func WithStartTimeout(d time.Duration) PostgresOption {
+	return pgtest.WithStartTimeout(d)
+}
+
+// StartPostgres initializes database cluster with initdb in temporary
+// directory, starts postgres on random local port and returns pool connected
+// to it. Server is stopped and its data removed in t.Cleanup. Test is skipped
+// if initdb or postgres binary is not found on PATH or in
+// /usr/lib/postgresql/*/bin, or if it runs as root which postgres refuses.
+func StartPostgres(t testing.TB, opts ...PostgresOption) *pgxpool.Pool {
+	t.Helper()
+	return pgtest.Start(t, opts...)
+}
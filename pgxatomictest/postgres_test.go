@@ -0,0 +1,20 @@
+package pgxatomictest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartPostgres(t *testing.T) {
+	pool := StartPostgres(t, WithSetting("max_connections", "20"))
+
+	var maxConns, maxPrepared string
+	err := pool.QueryRow(context.Background(), "SELECT current_setting('max_connections'), current_setting('max_prepared_transactions')").
+		Scan(&maxConns, &maxPrepared)
+	require.NoError(t, err)
+	assert.Equal(t, "20", maxConns)
+	assert.Equal(t, "10", maxPrepared)
+}
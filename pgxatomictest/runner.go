@@ -0,0 +1,136 @@
+package pgxatomictest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ysomad/pgxatomic"
+)
+
+// Run is record of single FakeRunner.Run call.
+type Run struct {
+	// Tx is transaction passed to txFunc in context, savepoint of parent
+	// transaction for nested runs.
+	Tx *RecordingTx
+
+	// Called reports whether txFunc was called inside transaction.
+	Called bool
+
+	// Nested reports whether Run was called inside another Run.
+	Nested bool
+
+	// NestedRuns is number of Run calls made inside txFunc.
+	NestedRuns int
+
+	// Err is error returned by Run.
+	Err error
+}
+
+// State returns state of run transaction, Active if it has not begun.
+func (r *Run) State() State {
+	if r.Tx == nil {
+		return Active
+	}
+	return r.Tx.State()
+}
+
+// FakeRunner runs txFunc in RecordingTx without database and records each
+// call. Nested runs use savepoints of the outer transaction.
+type FakeRunner struct {
+	// BeginErr is returned by Run without calling txFunc.
+	BeginErr error
+
+	// NewTx creates transaction of each outermost run, NewRecordingTx is
+	// used by default.
+	NewTx func() *RecordingTx
+
+	mu   sync.Mutex
+	runs []*Run
+}
+
+var _ pgxatomic.TxRunner = (*FakeRunner)(nil)
+
+type runKey struct{}
+
+func (r *FakeRunner) Run(ctx context.Context, txFunc func(ctx context.Context) error, _ ...pgxatomic.RunOption) (err error) {
+	run := &Run{}
+	r.mu.Lock()
+	r.runs = append(r.runs, run)
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		run.Err = err
+		r.mu.Unlock()
+	}()
+
+	if r.BeginErr != nil {
+		return r.BeginErr
+	}
+
+	parent, _ := ctx.Value(runKey{}).(*Run)
+	if parent != nil {
+		r.mu.Lock()
+		parent.NestedRuns++
+		run.Nested = true
+		r.mu.Unlock()
+
+		sp, err := parent.Tx.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		run.Tx = sp.(*RecordingTx)
+	} else {
+		run.Tx = r.newTx()
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = run.Tx.Rollback(ctx)
+			panic(p)
+		}
+	}()
+
+	run.Called = true
+	txCtx := context.WithValue(pgxatomic.WithTx(ctx, run.Tx), runKey{}, run)
+	if err := txFunc(txCtx); err != nil {
+		_ = run.Tx.Rollback(ctx)
+		return err
+	}
+	return run.Tx.Commit(ctx)
+}
+
+func (r *FakeRunner) newTx() *RecordingTx {
+	if r.NewTx != nil {
+		return r.NewTx()
+	}
+	return NewRecordingTx()
+}
+
+// Runs returns records of all Run calls including nested ones in order
+// they were started.
+func (r *FakeRunner) Runs() []*Run {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*Run(nil), r.runs...)
+}
+
+// LastRun returns record of the last outermost Run call or nil.
+func (r *FakeRunner) LastRun() *Run {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := len(r.runs) - 1; i >= 0; i-- {
+		if !r.runs[i].Nested {
+			return r.runs[i]
+		}
+	}
+	return nil
+}
+
+// State returns state of the last outermost run, Active if there is none.
+func (r *FakeRunner) State() State {
+	if run := r.LastRun(); run != nil {
+		return run.State()
+	}
+	return Active
+}
@@ -0,0 +1,181 @@
+package pgxatomictest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ysomad/pgxatomic"
+)
+
+var errTest = errors.New("test error")
+
+type recordingTB struct {
+	testing.TB
+	errors int
+}
+
+func (t *recordingTB) Helper() {}
+
+func (t *recordingTB) Errorf(string, ...any) { t.errors++ }
+
+func TestFakeRunner(t *testing.T) {
+	r := &FakeRunner{}
+	ctx := context.Background()
+
+	err := r.Run(ctx, func(ctx context.Context) error {
+		_, err := pgxatomic.Exec(ctx, nil, "INSERT INTO orders (cost) VALUES ($1)", 100)
+		require.NoError(t, err)
+
+		return r.Run(ctx, func(ctx context.Context) error {
+			_, err := pgxatomic.Exec(ctx, nil, "UPDATE balance SET amount = amount - $1", 100)
+			require.NoError(t, err)
+			return errTest
+		})
+	})
+	assert.ErrorIs(t, err, errTest)
+
+	runs := r.Runs()
+	require.Len(t, runs, 2)
+
+	outer, inner := runs[0], runs[1]
+	assert.True(t, outer.Called)
+	assert.False(t, outer.Nested)
+	assert.Equal(t, 1, outer.NestedRuns)
+	assert.ErrorIs(t, outer.Err, errTest)
+	AssertRolledBack(t, outer)
+	AssertRolledBack(t, r)
+
+	assert.True(t, inner.Nested)
+	assert.Equal(t, []*RecordingTx{inner.Tx}, outer.Tx.Savepoints())
+	AssertRolledBack(t, inner.Tx)
+
+	assert.Equal(t, []Statement{
+		{SQL: "INSERT INTO orders (cost) VALUES ($1)", Args: []any{100}},
+		{SQL: "SAVEPOINT"},
+		{SQL: "UPDATE balance SET amount = amount - $1", Args: []any{100}},
+		{SQL: "ROLLBACK TO SAVEPOINT"},
+		{SQL: "ROLLBACK"},
+	}, outer.Tx.Statements())
+
+	assert.Same(t, outer, r.LastRun())
+}
+
+func TestFakeRunner_Commit(t *testing.T) {
+	r := &FakeRunner{}
+
+	err := r.Run(context.Background(), func(ctx context.Context) error {
+		return r.Run(ctx, func(ctx context.Context) error { return nil })
+	})
+	require.NoError(t, err)
+
+	AssertCommitted(t, r)
+	assert.Equal(t, []string{"SAVEPOINT", "RELEASE SAVEPOINT", "COMMIT"}, r.LastRun().Tx.SQL())
+}
+
+func TestFakeRunner_Errors(t *testing.T) {
+	r := &FakeRunner{BeginErr: errTest}
+	err := r.Run(context.Background(), func(ctx context.Context) error {
+		t.Fatal("txFunc must not be called")
+		return nil
+	})
+	assert.ErrorIs(t, err, errTest)
+	assert.False(t, r.LastRun().Called)
+	assert.Equal(t, Active, r.State())
+
+	r = &FakeRunner{NewTx: func() *RecordingTx {
+		return &RecordingTx{CommitErr: errTest}
+	}}
+	err = r.Run(context.Background(), func(ctx context.Context) error { return nil })
+	assert.ErrorIs(t, err, errTest)
+	AssertRolledBack(t, r)
+}
+
+func TestFakeRunner_WithRunner(t *testing.T) {
+	tx := NewRecordingTx()
+	tx.ExecFunc = func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+		return pgconn.NewCommandTag("UPDATE 1"), nil
+	}
+
+	runner, err := pgxatomic.NewRunner(starter{tx}, pgx.TxOptions{})
+	require.NoError(t, err)
+
+	err = runner.Run(context.Background(), func(ctx context.Context) error {
+		tag, err := pgxatomic.Exec(ctx, nil, "UPDATE users SET name = $1", "John")
+		assert.Equal(t, int64(1), tag.RowsAffected())
+		return err
+	})
+	require.NoError(t, err)
+
+	AssertCommitted(t, tx)
+	AssertExecuted(t, tx, "UPDATE users SET name = $1")
+}
+
+type starter struct {
+	tx *RecordingTx
+}
+
+func (s starter) BeginTx(context.Context, pgx.TxOptions) (pgx.Tx, error) {
+	return s.tx, nil
+}
+
+func TestRecordingTx(t *testing.T) {
+	ctx := context.Background()
+	tx := NewRecordingTx()
+
+	var id int
+	assert.ErrorIs(t, tx.QueryRow(ctx, "SELECT id FROM users").Scan(&id), pgx.ErrNoRows)
+
+	rows, err := tx.Query(ctx, "SELECT id FROM users")
+	require.NoError(t, err)
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+
+	b := &pgx.Batch{}
+	b.Queue("INSERT INTO users (id) VALUES ($1)", 1)
+	b.Queue("INSERT INTO users (id) VALUES ($1)", 2)
+	require.NoError(t, tx.SendBatch(ctx, b).Close())
+
+	n, err := tx.CopyFrom(ctx, pgx.Identifier{"users"}, []string{"id"}, pgx.CopyFromRows([][]any{{3}, {4}}))
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, n)
+
+	require.NoError(t, tx.Commit(ctx))
+	assert.ErrorIs(t, tx.Rollback(ctx), pgx.ErrTxClosed)
+
+	_, err = tx.Exec(ctx, "SELECT 1")
+	assert.ErrorIs(t, err, pgx.ErrTxClosed)
+
+	assert.Equal(t, []string{
+		"SELECT id FROM users",
+		"SELECT id FROM users",
+		"INSERT INTO users (id) VALUES ($1)",
+		"INSERT INTO users (id) VALUES ($1)",
+		`COPY "users"`,
+		"COMMIT",
+	}, tx.SQL())
+}
+
+func TestAssertions(t *testing.T) {
+	tx := NewRecordingTx()
+	rt := &recordingTB{TB: t}
+
+	assert.False(t, AssertCommitted(rt, tx))
+	assert.False(t, AssertRolledBack(rt, tx))
+	assert.False(t, AssertExecuted(rt, tx, "SELECT 1"))
+	assert.Equal(t, 3, rt.errors)
+
+	_, err := tx.Exec(context.Background(), "SELECT 1")
+	require.NoError(t, err)
+	require.NoError(t, tx.Rollback(context.Background()))
+
+	assert.True(t, AssertRolledBack(rt, tx))
+	assert.True(t, AssertExecuted(rt, tx, "SELECT 1"))
+	assert.Equal(t, 3, rt.errors)
+}
@@ -0,0 +1,305 @@
+package pgxatomictest
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Response is reply of Server to query.
+type Response struct {
+	// Tag is command tag, it is derived from query if empty.
+	Tag string
+
+	// Columns and Rows are returned as text values.
+	Columns []string
+	Rows    [][]string
+
+	// Err is sent as ErrorResponse instead of result.
+	Err *pgconn.PgError
+}
+
+// Server is in-process Postgres backend speaking wire protocol, it records
+// queries received over the wire and tracks transaction status of each
+// connection so pgx sees BEGIN, COMMIT, ROLLBACK and savepoints as from real
+// server. Only simple query protocol is supported, ConnString sets
+// default_query_exec_mode=simple_protocol so arguments are sent inlined.
+type Server struct {
+	ln net.Listener
+
+	mu      sync.Mutex
+	respond func(sql string) (Response, bool)
+	queries []string
+	conns   map[net.Conn]struct{}
+
+	wg sync.WaitGroup
+}
+
+// NewServer starts Server on random local port and stops it in t.Cleanup.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("pgxatomictest: listen: %s", err)
+	}
+
+	s := &Server{ln: ln, conns: make(map[net.Conn]struct{})}
+	s.wg.Go(s.serve)
+	t.Cleanup(s.close)
+
+	return s
+}
+
+// ConnString returns connection string of server.
+func (s *Server) ConnString() string {
+	return fmt.Sprintf("postgres://postgres@%s/postgres?sslmode=disable&default_query_exec_mode=simple_protocol", s.ln.Addr())
+}
+
+// Respond sets fn scripting replies, default reply is used if fn returns false.
+func (s *Server) Respond(fn func(sql string) (Response, bool)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.respond = fn
+}
+
+// Queries returns queries received by server in order.
+func (s *Server) Queries() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.queries...)
+}
+
+// Reset forgets received queries.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queries = nil
+}
+
+func (s *Server) close() {
+	s.ln.Close()
+	s.mu.Lock()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	for {
+		c, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Go(func() {
+			defer func() {
+				c.Close()
+				s.mu.Lock()
+				delete(s.conns, c)
+				s.mu.Unlock()
+			}()
+			_ = s.handle(c)
+		})
+	}
+}
+
+// txStatus values sent in ReadyForQuery.
+const (
+	txIdle   = 'I'
+	txActive = 'T'
+	txFailed = 'E'
+)
+
+var errUnsupported = errors.New("pgxatomictest: unsupported message")
+
+func (s *Server) handle(c net.Conn) error {
+	b := pgproto3.NewBackend(c, c)
+
+	if err := s.startup(c, b); err != nil {
+		return err
+	}
+
+	status := byte(txIdle)
+	skipToSync := false
+
+	for {
+		msg, err := b.Receive()
+		if err != nil {
+			return err
+		}
+
+		switch msg := msg.(type) {
+		case *pgproto3.Query:
+			status = s.query(b, msg.String, status)
+			b.Send(&pgproto3.ReadyForQuery{TxStatus: status})
+		case *pgproto3.Sync:
+			skipToSync = false
+			b.Send(&pgproto3.ReadyForQuery{TxStatus: status})
+		case *pgproto3.Terminate:
+			return nil
+		default:
+			// extended protocol is not supported, report error once and
+			// discard messages until Sync as real server does
+			if !skipToSync {
+				skipToSync = true
+				if status == txActive {
+					status = txFailed
+				}
+				b.Send(&pgproto3.ErrorResponse{
+					Severity: "ERROR",
+					Code:     "0A000",
+					Message:  fmt.Sprintf("%s: %T", errUnsupported, msg),
+				})
+			}
+		}
+
+		if err := b.Flush(); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) startup(c net.Conn, b *pgproto3.Backend) error {
+	for {
+		msg, err := b.ReceiveStartupMessage()
+		if err != nil {
+			return err
+		}
+
+		switch msg.(type) {
+		case *pgproto3.SSLRequest, *pgproto3.GSSEncRequest:
+			if _, err := c.Write([]byte{'N'}); err != nil {
+				return err
+			}
+		case *pgproto3.StartupMessage:
+			b.Send(&pgproto3.AuthenticationOk{})
+			b.Send(&pgproto3.ParameterStatus{Name: "server_version", Value: "17.0"})
+			b.Send(&pgproto3.ParameterStatus{Name: "client_encoding", Value: "UTF8"})
+			b.Send(&pgproto3.ParameterStatus{Name: "standard_conforming_strings", Value: "on"})
+			b.Send(&pgproto3.BackendKeyData{ProcessID: 1, SecretKey: 1})
+			b.Send(&pgproto3.ReadyForQuery{TxStatus: txIdle})
+			return b.Flush()
+		default:
+			return errUnsupported
+		}
+	}
+}
+
+// query replies to simple query and returns new transaction status.
+func (s *Server) query(b *pgproto3.Backend, sql string, status byte) byte {
+	s.mu.Lock()
+	s.queries = append(s.queries, sql)
+	respond := s.respond
+	s.mu.Unlock()
+
+	resp, ok := Response{}, false
+	if respond != nil {
+		resp, ok = respond(sql)
+	}
+
+	cmd := strings.ToLower(strings.TrimSpace(sql))
+
+	if status == txFailed && !isTxEnd(cmd) {
+		resp = Response{Err: &pgconn.PgError{
+			Code:    "25P02",
+			Message: "current transaction is aborted, commands ignored until end of transaction block",
+		}}
+		ok = true
+	}
+
+	if ok && resp.Err != nil {
+		b.Send(&pgproto3.ErrorResponse{
+			Severity:       "ERROR",
+			Code:           resp.Err.Code,
+			Message:        resp.Err.Message,
+			Detail:         resp.Err.Detail,
+			ConstraintName: resp.Err.ConstraintName,
+		})
+		if status == txActive {
+			return txFailed
+		}
+		return status
+	}
+
+	if len(resp.Columns) > 0 {
+		fields := make([]pgproto3.FieldDescription, len(resp.Columns))
+		for i, name := range resp.Columns {
+			fields[i] = pgproto3.FieldDescription{
+				Name:         []byte(name),
+				DataTypeOID:  pgtype.TextOID,
+				DataTypeSize: -1,
+				TypeModifier: -1,
+			}
+		}
+		b.Send(&pgproto3.RowDescription{Fields: fields})
+		for _, row := range resp.Rows {
+			values := make([][]byte, len(row))
+			for i, v := range row {
+				values[i] = []byte(v)
+			}
+			b.Send(&pgproto3.DataRow{Values: values})
+		}
+	}
+
+	tag, next := commandTag(cmd, status, len(resp.Rows))
+	if resp.Tag != "" {
+		tag = resp.Tag
+	}
+	b.Send(&pgproto3.CommandComplete{CommandTag: []byte(tag)})
+
+	return next
+}
+
+func isTxEnd(cmd string) bool {
+	return strings.HasPrefix(cmd, "commit") || strings.HasPrefix(cmd, "rollback") ||
+		strings.HasPrefix(cmd, "end") || strings.HasPrefix(cmd, "abort")
+}
+
+// commandTag returns default command tag of cmd and transaction status after it.
+func commandTag(cmd string, status byte, rows int) (string, byte) {
+	switch {
+	case strings.HasPrefix(cmd, "begin"), strings.HasPrefix(cmd, "start transaction"):
+		return "BEGIN", txActive
+	case strings.HasPrefix(cmd, "commit prepared"):
+		return "COMMIT PREPARED", status
+	case strings.HasPrefix(cmd, "rollback prepared"):
+		return "ROLLBACK PREPARED", status
+	case strings.HasPrefix(cmd, "prepare transaction"):
+		if status == txFailed {
+			return "ROLLBACK", txIdle
+		}
+		return "PREPARE TRANSACTION", txIdle
+	case strings.HasPrefix(cmd, "commit"), strings.HasPrefix(cmd, "end"):
+		if status == txFailed {
+			return "ROLLBACK", txIdle
+		}
+		return "COMMIT", txIdle
+	case strings.HasPrefix(cmd, "rollback to"):
+		return "ROLLBACK", txActive
+	case strings.HasPrefix(cmd, "rollback"), strings.HasPrefix(cmd, "abort"):
+		return "ROLLBACK", txIdle
+	case strings.HasPrefix(cmd, "savepoint"):
+		return "SAVEPOINT", status
+	case strings.HasPrefix(cmd, "release"):
+		return "RELEASE", status
+	case strings.HasPrefix(cmd, "insert"):
+		return "INSERT 0 " + fmt.Sprint(rows), status
+	case strings.HasPrefix(cmd, "update"), strings.HasPrefix(cmd, "delete"):
+		return strings.ToUpper(strings.Fields(cmd)[0]) + " " + fmt.Sprint(rows), status
+	default:
+		return "SELECT " + fmt.Sprint(rows), status
+	}
+}
@@ -0,0 +1,213 @@
+package pgxatomictest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ysomad/pgxatomic"
+	"github.com/ysomad/pgxatomic/pgerr"
+)
+
+func serverPool(t *testing.T) (*Server, *pgxpool.Pool) {
+	t.Helper()
+
+	srv := NewServer(t)
+
+	pool, err := pgxpool.New(context.Background(), srv.ConnString())
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	return srv, pool
+}
+
+func TestServer_Runner(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  pgx.TxOptions
+		fnErr error
+		want  []string
+	}{
+		{
+			name: "commit",
+			want: []string{"begin", "INSERT INTO users(name) VALUES ( 'John' )", "commit"},
+		},
+		{
+			name:  "rollback",
+			fnErr: errTest,
+			want:  []string{"begin", "INSERT INTO users(name) VALUES ( 'John' )", "rollback"},
+		},
+		{
+			name: "serializable read only",
+			opts: pgx.TxOptions{IsoLevel: pgx.Serializable, AccessMode: pgx.ReadOnly, DeferrableMode: pgx.Deferrable},
+			want: []string{"begin isolation level serializable read only deferrable", "INSERT INTO users(name) VALUES ( 'John' )", "commit"},
+		},
+		{
+			name: "read committed",
+			opts: pgx.TxOptions{IsoLevel: pgx.ReadCommitted},
+			want: []string{"begin isolation level read committed", "INSERT INTO users(name) VALUES ( 'John' )", "commit"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, pool := serverPool(t)
+			p, err := pgxatomic.NewPool(pool)
+			require.NoError(t, err)
+
+			runner, err := pgxatomic.NewRunner(pool, tt.opts)
+			require.NoError(t, err)
+
+			err = runner.Run(context.Background(), func(ctx context.Context) error {
+				if _, err := p.Exec(ctx, "INSERT INTO users(name) VALUES ($1)", "John"); err != nil {
+					return err
+				}
+				return tt.fnErr
+			})
+			assert.ErrorIs(t, err, tt.fnErr)
+			assert.Equal(t, tt.want, srv.Queries())
+		})
+	}
+}
+
+func TestServer_CanceledRollback(t *testing.T) {
+	srv, pool := serverPool(t)
+
+	runner, err := pgxatomic.NewRunner(pool, pgx.TxOptions{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err = runner.Run(ctx, func(ctx context.Context) error {
+		cancel()
+		return ctx.Err()
+	})
+
+	var txErr *pgxatomic.TxError
+	assert.False(t, errors.As(err, &txErr), "canceled transaction must be rolled back: %v", err)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, []string{"begin", "rollback"}, srv.Queries())
+}
+
+func TestServer_Propagation(t *testing.T) {
+	tests := []struct {
+		name        string
+		propagation pgxatomic.Propagation
+		nestedErr   error
+		want        []string
+	}{
+		{
+			name:        "new",
+			propagation: pgxatomic.PropagationNew,
+			want:        []string{"begin", "begin", "SELECT 2", "commit", "commit"},
+		},
+		{
+			name:        "nested",
+			propagation: pgxatomic.PropagationNested,
+			want:        []string{"begin", "savepoint sp_1", "SELECT 2", "release savepoint sp_1", "commit"},
+		},
+		{
+			name:        "nested rollback",
+			propagation: pgxatomic.PropagationNested,
+			nestedErr:   errTest,
+			want:        []string{"begin", "savepoint sp_1", "SELECT 2", "rollback to savepoint sp_1", "commit"},
+		},
+		{
+			name:        "join",
+			propagation: pgxatomic.PropagationJoin,
+			want:        []string{"begin", "SELECT 2", "commit"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, pool := serverPool(t)
+
+			runner, err := pgxatomic.NewRunner(pool, pgx.TxOptions{}, pgxatomic.WithPropagation(tt.propagation))
+			require.NoError(t, err)
+
+			err = runner.Run(context.Background(), func(ctx context.Context) error {
+				err := runner.Run(ctx, func(ctx context.Context) error {
+					if _, err := pgxatomic.Exec(ctx, pool, "SELECT 2"); err != nil {
+						return err
+					}
+					return tt.nestedErr
+				})
+				assert.ErrorIs(t, err, tt.nestedErr)
+				return nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, srv.Queries())
+		})
+	}
+}
+
+func TestServer_CommitError(t *testing.T) {
+	srv, pool := serverPool(t)
+	srv.Respond(func(sql string) (Response, bool) {
+		if sql == "commit" {
+			return Response{Err: &pgconn.PgError{Code: pgerr.CodeSerializationFailure, Message: "could not serialize access"}}, true
+		}
+		return Response{}, false
+	})
+
+	runner, err := pgxatomic.NewRunner(pool, pgx.TxOptions{IsoLevel: pgx.Serializable}, pgxatomic.WithRetry(2))
+	require.NoError(t, err)
+
+	err = runner.Run(context.Background(), func(ctx context.Context) error { return nil })
+
+	var commitErr *pgxatomic.CommitError
+	require.ErrorAs(t, err, &commitErr)
+	assert.Equal(t, pgxatomic.CommitAborted, commitErr.Outcome)
+	assert.True(t, pgerr.IsSerializationFailure(err))
+	assert.Equal(t, []string{
+		"begin isolation level serializable", "commit",
+		"begin isolation level serializable", "commit",
+	}, srv.Queries())
+}
+
+func TestServer_QueryRow(t *testing.T) {
+	srv, pool := serverPool(t)
+	srv.Respond(func(sql string) (Response, bool) {
+		if strings.HasPrefix(sql, "SELECT name") {
+			return Response{Columns: []string{"name"}, Rows: [][]string{{"John"}}}, true
+		}
+		return Response{}, false
+	})
+
+	p, err := pgxatomic.NewPool(pool)
+	require.NoError(t, err)
+
+	var name string
+	err = p.QueryRow(context.Background(), "SELECT name FROM users WHERE id = $1", 1).Scan(&name)
+	require.NoError(t, err)
+	assert.Equal(t, "John", name)
+	assert.Equal(t, []string{"SELECT name FROM users WHERE id =  '1' "}, srv.Queries())
+}
+
+func TestServer_FailedTx(t *testing.T) {
+	srv, pool := serverPool(t)
+	srv.Respond(func(sql string) (Response, bool) {
+		if strings.HasPrefix(sql, "INSERT") {
+			return Response{Err: &pgconn.PgError{Code: pgerr.CodeUniqueViolation, ConstraintName: "users_name_key"}}, true
+		}
+		return Response{}, false
+	})
+
+	runner, err := pgxatomic.NewRunner(pool, pgx.TxOptions{})
+	require.NoError(t, err)
+
+	err = runner.Run(context.Background(), func(ctx context.Context) error {
+		_, _ = pgxatomic.Exec(ctx, pool, "INSERT INTO users(name) VALUES ('John')")
+		_, err := pgxatomic.Exec(ctx, pool, "SELECT 1")
+		return err
+	})
+	assert.Equal(t, "25P02", pgerr.Code(err))
+	assert.Equal(t, []string{"begin", "INSERT INTO users(name) VALUES ('John')", "SELECT 1", "rollback"}, srv.Queries())
+}
@@ -0,0 +1,315 @@
+// Package pgxatomictest provides test doubles of pgxatomic transactions for
+// unit tests of code using pgxatomic.
+package pgxatomictest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// State is state of RecordingTx.
+type State int
+
+const (
+	Active State = iota
+	Committed
+	RolledBack
+)
+
+func (s State) String() string {
+	switch s {
+	case Active:
+		return "active"
+	case Committed:
+		return "committed"
+	case RolledBack:
+		return "rolled back"
+	default:
+		return "unknown"
+	}
+}
+
+// Statement is SQL statement captured by RecordingTx.
+type Statement struct {
+	SQL  string
+	Args []any
+}
+
+var _ pgx.Tx = (*RecordingTx)(nil)
+
+// RecordingTx is pgx.Tx capturing executed statements without database.
+// Savepoints started with Begin record statements into their parent.
+type RecordingTx struct {
+	// ExecFunc, QueryFunc and QueryRowFunc override results of statements.
+	// By default Exec returns empty command tag, Query returns no rows and
+	// QueryRow returns row which Scan fails with pgx.ErrNoRows.
+	ExecFunc     func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	QueryFunc    func(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRowFunc func(ctx context.Context, sql string, args ...any) pgx.Row
+
+	// CommitErr is returned by Commit, transaction is rolled back then.
+	CommitErr error
+
+	root   *RecordingTx
+	parent *RecordingTx
+
+	mu         sync.Mutex
+	state      State
+	statements []Statement
+	savepoints []*RecordingTx
+}
+
+func NewRecordingTx() *RecordingTx {
+	return &RecordingTx{}
+}
+
+// State returns state of transaction.
+func (tx *RecordingTx) State() State {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	return tx.state
+}
+
+// Statements returns statements executed in transaction and its savepoints.
+func (tx *RecordingTx) Statements() []Statement {
+	r := tx.rootTx()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Statement(nil), r.statements...)
+}
+
+// SQL returns SQL of executed statements.
+func (tx *RecordingTx) SQL() []string {
+	stmts := tx.Statements()
+	sqls := make([]string, len(stmts))
+	for i, s := range stmts {
+		sqls[i] = s.SQL
+	}
+	return sqls
+}
+
+// Savepoints returns savepoints started with Begin.
+func (tx *RecordingTx) Savepoints() []*RecordingTx {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	return append([]*RecordingTx(nil), tx.savepoints...)
+}
+
+func (tx *RecordingTx) rootTx() *RecordingTx {
+	if tx.root != nil {
+		return tx.root
+	}
+	return tx
+}
+
+// record captures statement, pgx.ErrTxClosed is returned if tx is closed.
+func (tx *RecordingTx) record(sql string, args []any) error {
+	if tx.State() != Active {
+		return pgx.ErrTxClosed
+	}
+	r := tx.rootTx()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statements = append(r.statements, Statement{SQL: sql, Args: args})
+	return nil
+}
+
+func (tx *RecordingTx) close(s State) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.state != Active {
+		return pgx.ErrTxClosed
+	}
+	tx.state = s
+	return nil
+}
+
+// Begin starts savepoint sharing statement results of tx.
+func (tx *RecordingTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	if err := tx.record("SAVEPOINT", nil); err != nil {
+		return nil, err
+	}
+	sp := &RecordingTx{
+		ExecFunc:     tx.ExecFunc,
+		QueryFunc:    tx.QueryFunc,
+		QueryRowFunc: tx.QueryRowFunc,
+		root:         tx.rootTx(),
+		parent:       tx,
+	}
+	tx.mu.Lock()
+	tx.savepoints = append(tx.savepoints, sp)
+	tx.mu.Unlock()
+	return sp, nil
+}
+
+func (tx *RecordingTx) Commit(ctx context.Context) error {
+	if tx.CommitErr != nil {
+		if err := tx.close(RolledBack); err != nil {
+			return err
+		}
+		return tx.CommitErr
+	}
+	if err := tx.close(Committed); err != nil {
+		return err
+	}
+	sql := "COMMIT"
+	if tx.parent != nil {
+		sql = "RELEASE SAVEPOINT"
+	}
+	tx.rootTx().appendStatement(sql)
+	return nil
+}
+
+func (tx *RecordingTx) Rollback(ctx context.Context) error {
+	if err := tx.close(RolledBack); err != nil {
+		return err
+	}
+	sql := "ROLLBACK"
+	if tx.parent != nil {
+		sql = "ROLLBACK TO SAVEPOINT"
+	}
+	tx.rootTx().appendStatement(sql)
+	return nil
+}
+
+func (tx *RecordingTx) appendStatement(sql string) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.statements = append(tx.statements, Statement{SQL: sql})
+}
+
+func (tx *RecordingTx) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	if err := tx.record("COPY "+tableName.Sanitize(), nil); err != nil {
+		return 0, err
+	}
+	var n int64
+	for rowSrc.Next() {
+		n++
+	}
+	return n, rowSrc.Err()
+}
+
+func (tx *RecordingTx) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	res := &batchResults{ctx: ctx, tx: tx}
+	for _, q := range b.QueuedQueries {
+		res.queries = append(res.queries, Statement{SQL: q.SQL, Args: q.Arguments})
+	}
+	return res
+}
+
+func (tx *RecordingTx) LargeObjects() pgx.LargeObjects {
+	return pgx.LargeObjects{}
+}
+
+func (tx *RecordingTx) Prepare(ctx context.Context, name, sql string) (*pgconn.StatementDescription, error) {
+	if tx.State() != Active {
+		return nil, pgx.ErrTxClosed
+	}
+	return &pgconn.StatementDescription{Name: name, SQL: sql}, nil
+}
+
+func (tx *RecordingTx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	if err := tx.record(sql, args); err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	if tx.ExecFunc != nil {
+		return tx.ExecFunc(ctx, sql, args...)
+	}
+	return pgconn.CommandTag{}, nil
+}
+
+func (tx *RecordingTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	if err := tx.record(sql, args); err != nil {
+		return nil, err
+	}
+	if tx.QueryFunc != nil {
+		return tx.QueryFunc(ctx, sql, args...)
+	}
+	return emptyRows{}, nil
+}
+
+func (tx *RecordingTx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	if err := tx.record(sql, args); err != nil {
+		return errRow{err: err}
+	}
+	if tx.QueryRowFunc != nil {
+		return tx.QueryRowFunc(ctx, sql, args...)
+	}
+	return errRow{err: pgx.ErrNoRows}
+}
+
+// Conn returns nil since there is no underlying connection.
+func (tx *RecordingTx) Conn() *pgx.Conn {
+	return nil
+}
+
+type emptyRows struct{}
+
+func (emptyRows) Close()                                       {}
+func (emptyRows) Err() error                                   { return nil }
+func (emptyRows) CommandTag() pgconn.CommandTag                { return pgconn.CommandTag{} }
+func (emptyRows) FieldDescriptions() []pgconn.FieldDescription { return nil }
+func (emptyRows) Next() bool                                   { return false }
+func (emptyRows) Scan(...any) error                            { return pgx.ErrNoRows }
+func (emptyRows) Values() ([]any, error)                       { return nil, nil }
+func (emptyRows) RawValues() [][]byte                          { return nil }
+func (emptyRows) Conn() *pgx.Conn                              { return nil }
+
+type errRow struct {
+	err error
+}
+
+func (r errRow) Scan(...any) error { return r.err }
+
+// batchResults executes queued queries on tx one by one.
+type batchResults struct {
+	ctx     context.Context
+	tx      *RecordingTx
+	queries []Statement
+	i       int
+}
+
+func (b *batchResults) next() (Statement, error) {
+	if b.i >= len(b.queries) {
+		return Statement{}, pgx.ErrNoRows
+	}
+	q := b.queries[b.i]
+	b.i++
+	return q, nil
+}
+
+func (b *batchResults) Exec() (pgconn.CommandTag, error) {
+	q, err := b.next()
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	return b.tx.Exec(b.ctx, q.SQL, q.Args...)
+}
+
+func (b *batchResults) Query() (pgx.Rows, error) {
+	q, err := b.next()
+	if err != nil {
+		return nil, err
+	}
+	return b.tx.Query(b.ctx, q.SQL, q.Args...)
+}
+
+func (b *batchResults) QueryRow() pgx.Row {
+	q, err := b.next()
+	if err != nil {
+		return errRow{err: err}
+	}
+	return b.tx.QueryRow(b.ctx, q.SQL, q.Args...)
+}
+
+func (b *batchResults) Close() error {
+	for b.i < len(b.queries) {
+		if _, err := b.Exec(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
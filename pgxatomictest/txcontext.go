@@ -0,0 +1,33 @@
+package pgxatomictest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/ysomad/pgxatomic"
+)
+
+// TxContext begins transaction using db and returns context carrying it,
+// transaction is rolled back in t.Cleanup so tests can share one schema
+// without truncating tables. pgxatomic.Runner.Run called with returned context
+// starts savepoint instead of committing.
+func TxContext(t testing.TB, db pgxatomic.TxStarter) context.Context {
+	t.Helper()
+
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		t.Fatalf("pgxatomictest: begin: %s", err)
+	}
+
+	t.Cleanup(func() {
+		if err := tx.Rollback(ctx); err != nil {
+			t.Errorf("pgxatomictest: rollback: %s", err)
+		}
+	})
+
+	return pgxatomic.ContextWithPropagation(pgxatomic.WithTx(ctx, tx), pgxatomic.PropagationNested)
+}
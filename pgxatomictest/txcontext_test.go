@@ -0,0 +1,65 @@
+package pgxatomictest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ysomad/pgxatomic"
+	"github.com/ysomad/pgxatomic/internal/pgtest"
+)
+
+func TestTxContext(t *testing.T) {
+	tx := NewRecordingTx()
+
+	t.Run("nested run", func(t *testing.T) {
+		ctx := TxContext(t, starter{tx})
+		require.Equal(t, pgx.Tx(tx), pgxatomic.TxFromContext(ctx))
+
+		runner, err := pgxatomic.NewRunner(starter{NewRecordingTx()}, pgx.TxOptions{})
+		require.NoError(t, err)
+
+		err = runner.Run(ctx, func(ctx context.Context) error {
+			_, err := pgxatomic.Exec(ctx, nil, "INSERT INTO users(name) VALUES ($1)", "John")
+			return err
+		})
+		require.NoError(t, err)
+		assert.Equal(t, Active, tx.State())
+	})
+
+	AssertRolledBack(t, tx)
+	assert.Equal(t, []string{
+		"SAVEPOINT",
+		"INSERT INTO users(name) VALUES ($1)",
+		"RELEASE SAVEPOINT",
+		"ROLLBACK",
+	}, tx.SQL())
+}
+
+func TestTxContext_Postgres(t *testing.T) {
+	pool := pgtest.Pool(t)
+
+	t.Run("insert", func(t *testing.T) {
+		ctx := TxContext(t, pool)
+
+		_, err := pgxatomic.Exec(ctx, pool, "CREATE TABLE pgxatomictest_txcontext (id int)")
+		require.NoError(t, err)
+
+		runner, err := pgxatomic.NewRunner(pool, pgx.TxOptions{})
+		require.NoError(t, err)
+
+		err = runner.Run(ctx, func(ctx context.Context) error {
+			_, err := pgxatomic.Exec(ctx, pool, "INSERT INTO pgxatomictest_txcontext VALUES (1)")
+			return err
+		})
+		require.NoError(t, err)
+	})
+
+	var exists bool
+	err := pool.QueryRow(context.Background(), "SELECT to_regclass('pgxatomictest_txcontext') IS NOT NULL").Scan(&exists)
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
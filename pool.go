@@ -3,6 +3,8 @@ package pgxatomic
 import (
 	"context"
 	"errors"
+	"log/slog"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
@@ -12,24 +14,49 @@ import (
 // Pool wraps pgxpool.Pool query methods with pgxatomic corresponding functions
 // which injects pgx.Tx into context.
 type Pool struct {
-	p *pgxpool.Pool
+	p            *pgxpool.Pool
+	logger       *txLogger
+	interceptors []QueryInterceptor
 }
 
-func NewPool(p *pgxpool.Pool) (Pool, error) {
+// PoolOption configures Pool.
+type PoolOption func(*Pool)
+
+// WithQueryLogger logs queries executed inside transaction to l.
+func WithQueryLogger(l *slog.Logger, opts LogOptions) PoolOption {
+	return func(p *Pool) {
+		p.logger = newTxLogger(l, opts)
+	}
+}
+
+func NewPool(p *pgxpool.Pool, opts ...PoolOption) (Pool, error) {
 	if p == nil {
 		return Pool{}, errors.New("pgxatomic: pool cannot be nil")
 	}
-	return Pool{p: p}, nil
+	pool := Pool{p: p}
+	for _, o := range opts {
+		o(&pool)
+	}
+	return pool, nil
 }
 
 func (p Pool) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
-	return Query(ctx, p.p, sql, args...)
+	start := time.Now()
+	rows, err := query(ctx, p.p, p.interceptors, sql, args)
+	p.logger.query(ctx, sql, args, time.Since(start), err)
+	return rows, err
 }
 
 func (p Pool) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
-	return QueryRow(ctx, p.p, sql, args...)
+	start := time.Now()
+	row := queryRow(ctx, p.p, p.interceptors, sql, args)
+	p.logger.query(ctx, sql, args, time.Since(start), nil)
+	return row
 }
 
 func (p Pool) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
-	return Exec(ctx, p.p, sql, args...)
+	start := time.Now()
+	tag, err := exec(ctx, p.p, p.interceptors, sql, args)
+	p.logger.query(ctx, sql, args, time.Since(start), err)
+	return tag, err
 }
@@ -13,10 +13,21 @@ type querier interface {
 
 // Query is a wrapper around pgx Query method.
 func Query(ctx context.Context, db querier, sql string, args ...any) (pgx.Rows, error) {
-	if tx := TxFromContext(ctx); tx != nil {
-		return tx.Query(ctx, sql, args...)
-	}
-	return db.Query(ctx, sql, args...)
+	return query(ctx, db, nil, sql, args)
+}
+
+func query(ctx context.Context, db querier, interceptors []QueryInterceptor, sql string, args []any) (pgx.Rows, error) {
+	tx := txFromContext(ctx)
+	st := Statement{Kind: StatementQuery, SQL: sql, Args: args, InTx: tx != nil}
+
+	res := intercept(ctx, interceptors, st, func(ctx context.Context, st Statement) StatementResult {
+		if tx != nil {
+			db = tx
+		}
+		rows, err := db.Query(ctx, st.SQL, st.Args...)
+		return StatementResult{Rows: rows, Err: err}
+	})
+	return res.Rows, res.Err
 }
 
 type executor interface {
@@ -25,10 +36,21 @@ type executor interface {
 
 // Exec is a wrapper around pgx Exec method.
 func Exec(ctx context.Context, db executor, sql string, args ...any) (pgconn.CommandTag, error) {
-	if tx := TxFromContext(ctx); tx != nil {
-		return tx.Exec(ctx, sql, args...)
-	}
-	return db.Exec(ctx, sql, args...)
+	return exec(ctx, db, nil, sql, args)
+}
+
+func exec(ctx context.Context, db executor, interceptors []QueryInterceptor, sql string, args []any) (pgconn.CommandTag, error) {
+	tx := txFromContext(ctx)
+	st := Statement{Kind: StatementExec, SQL: sql, Args: args, InTx: tx != nil}
+
+	res := intercept(ctx, interceptors, st, func(ctx context.Context, st Statement) StatementResult {
+		if tx != nil {
+			db = tx
+		}
+		tag, err := db.Exec(ctx, st.SQL, st.Args...)
+		return StatementResult{Tag: tag, Err: err}
+	})
+	return res.Tag, res.Err
 }
 
 type queryRower interface {
@@ -37,8 +59,18 @@ type queryRower interface {
 
 // QueryRow is a wrapper around pgx QueryRow method.
 func QueryRow(ctx context.Context, db queryRower, sql string, args ...any) pgx.Row {
-	if tx := TxFromContext(ctx); tx != nil {
-		return tx.QueryRow(ctx, sql, args...)
-	}
-	return db.QueryRow(ctx, sql, args...)
+	return queryRow(ctx, db, nil, sql, args)
+}
+
+func queryRow(ctx context.Context, db queryRower, interceptors []QueryInterceptor, sql string, args []any) pgx.Row {
+	tx := txFromContext(ctx)
+	st := Statement{Kind: StatementQueryRow, SQL: sql, Args: args, InTx: tx != nil}
+
+	res := intercept(ctx, interceptors, st, func(ctx context.Context, st Statement) StatementResult {
+		if tx != nil {
+			db = tx
+		}
+		return StatementResult{Row: db.QueryRow(ctx, st.SQL, st.Args...)}
+	})
+	return res.Row
 }
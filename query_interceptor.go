@@ -0,0 +1,108 @@
+package pgxatomic
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// StatementKind is pgxatomic function statement is executed with.
+type StatementKind int
+
+const (
+	StatementQuery StatementKind = iota + 1
+	StatementQueryRow
+	StatementExec
+)
+
+func (k StatementKind) String() string {
+	switch k {
+	case StatementQuery:
+		return "query"
+	case StatementQueryRow:
+		return "query_row"
+	case StatementExec:
+		return "exec"
+	default:
+		return "unknown"
+	}
+}
+
+// Statement is passed to QueryInterceptor, SQL and Args may be modified
+// before passing statement to next.
+type Statement struct {
+	Kind StatementKind
+	SQL  string
+	Args []any
+
+	// InTx reports whether statement is executed in transaction taken from context.
+	InTx bool
+}
+
+// StatementResult holds result of executed statement, Rows is set for
+// StatementQuery, Row for StatementQueryRow and Tag for StatementExec.
+// Err is never set for StatementQueryRow since pgx reports it on Scan.
+type StatementResult struct {
+	Rows pgx.Rows
+	Row  pgx.Row
+	Tag  pgconn.CommandTag
+	Err  error
+}
+
+// QueryInterceptor wraps execution of statement by Query, QueryRow and Exec,
+// it must call next to execute statement.
+type QueryInterceptor func(ctx context.Context, st Statement, next func(ctx context.Context, st Statement) StatementResult) StatementResult
+
+type queryInterceptorsKey struct{}
+
+// ContextWithQueryInterceptors returns context with interceptors applied to
+// Query, QueryRow and Exec functions called with it, interceptors are
+// appended to ones already set in ctx.
+func ContextWithQueryInterceptors(ctx context.Context, interceptors ...QueryInterceptor) context.Context {
+	existing := queryInterceptorsFromContext(ctx)
+	all := make([]QueryInterceptor, 0, len(existing)+len(interceptors))
+	all = append(all, existing...)
+	all = append(all, interceptors...)
+	return context.WithValue(ctx, queryInterceptorsKey{}, all)
+}
+
+func queryInterceptorsFromContext(ctx context.Context) []QueryInterceptor {
+	interceptors, _ := ctx.Value(queryInterceptorsKey{}).([]QueryInterceptor)
+	return interceptors
+}
+
+// WithQueryInterceptors registers interceptors applied to statements executed
+// via Pool, they are executed before interceptors set in context.
+func WithQueryInterceptors(interceptors ...QueryInterceptor) PoolOption {
+	return func(p *Pool) {
+		p.interceptors = append(p.interceptors, interceptors...)
+	}
+}
+
+// intercept executes st with exec wrapped in interceptors and interceptors
+// from context, first interceptor is the outermost one.
+func intercept(
+	ctx context.Context,
+	interceptors []QueryInterceptor,
+	st Statement,
+	exec func(ctx context.Context, st Statement) StatementResult,
+) StatementResult {
+	ctxInterceptors := queryInterceptorsFromContext(ctx)
+	if len(interceptors) == 0 && len(ctxInterceptors) == 0 {
+		return exec(ctx, st)
+	}
+
+	all := make([]QueryInterceptor, 0, len(interceptors)+len(ctxInterceptors))
+	all = append(all, interceptors...)
+	all = append(all, ctxInterceptors...)
+
+	next := exec
+	for i := len(all) - 1; i >= 0; i-- {
+		ic, n := all[i], next
+		next = func(ctx context.Context, st Statement) StatementResult {
+			return ic(ctx, st, n)
+		}
+	}
+	return next(ctx, st)
+}
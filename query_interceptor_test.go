@@ -0,0 +1,86 @@
+package pgxatomic
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestQueryInterceptors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockTx := NewMockTx(ctrl)
+	mockRows := NewMockRows(ctrl)
+	mockRow := NewMockRow(ctrl)
+
+	var calls []string
+	record := func(name string) QueryInterceptor {
+		return func(ctx context.Context, st Statement, next func(context.Context, Statement) StatementResult) StatementResult {
+			calls = append(calls, name+":"+st.Kind.String()+":"+boolString(st.InTx))
+			return next(ctx, st)
+		}
+	}
+	comment := func(ctx context.Context, st Statement, next func(context.Context, Statement) StatementResult) StatementResult {
+		st.SQL += " /* pgxatomic */"
+		return next(ctx, st)
+	}
+
+	p := Pool{interceptors: []QueryInterceptor{record("pool"), comment}}
+	ctx := ContextWithQueryInterceptors(context.Background(), record("ctx1"))
+	ctx = ContextWithQueryInterceptors(ctx, record("ctx2"))
+
+	mockTx.EXPECT().Query(gomock.Any(), "SELECT 1 /* pgxatomic */").Return(mockRows, nil)
+	mockTx.EXPECT().QueryRow(gomock.Any(), "SELECT $1 /* pgxatomic */", 1).Return(mockRow)
+	mockTx.EXPECT().Exec(gomock.Any(), "DELETE FROM users", 1).Return(pgconn.NewCommandTag("DELETE 1"), errTest)
+
+	txCtx := WithTx(ctx, mockTx)
+
+	rows, err := p.Query(txCtx, "SELECT 1")
+	assert.NoError(t, err)
+	assert.Equal(t, mockRows, rows)
+
+	row := p.QueryRow(txCtx, "SELECT $1", 1)
+	assert.Equal(t, mockRow, row)
+
+	var gotRes StatementResult
+	tag, err := Exec(ContextWithQueryInterceptors(txCtx,
+		func(ctx context.Context, st Statement, next func(context.Context, Statement) StatementResult) StatementResult {
+			gotRes = next(ctx, st)
+			return gotRes
+		}), mockTx, "DELETE FROM users", 1)
+	assert.ErrorIs(t, err, errTest)
+	assert.Equal(t, pgconn.NewCommandTag("DELETE 1"), tag)
+	assert.Equal(t, StatementResult{Tag: tag, Err: errTest}, gotRes)
+
+	assert.Equal(t, []string{
+		"pool:query:tx", "ctx1:query:tx", "ctx2:query:tx",
+		"pool:query_row:tx", "ctx1:query_row:tx", "ctx2:query_row:tx",
+		"ctx1:exec:tx", "ctx2:exec:tx",
+	}, calls)
+}
+
+func TestQueryInterceptors_NoTx(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := NewMockTx(ctrl)
+
+	var got Statement
+	ctx := ContextWithQueryInterceptors(context.Background(),
+		func(ctx context.Context, st Statement, next func(context.Context, Statement) StatementResult) StatementResult {
+			got = st
+			return StatementResult{Err: errTest}
+		})
+
+	_, err := Query(ctx, mockDB, "SELECT * FROM users WHERE id = $1", 42)
+	assert.ErrorIs(t, err, errTest)
+	assert.Equal(t, Statement{
+		Kind: StatementQuery,
+		SQL:  "SELECT * FROM users WHERE id = $1",
+		Args: []any{42},
+	}, got)
+}
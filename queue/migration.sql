@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS pgxatomic_jobs (
+    id           bigserial   PRIMARY KEY,
+    kind         text        NOT NULL,
+    args         jsonb       NOT NULL,
+    state        text        NOT NULL DEFAULT 'available',
+    attempts     integer     NOT NULL DEFAULT 0,
+    max_attempts integer     NOT NULL,
+    run_at       timestamptz NOT NULL DEFAULT now(),
+    unique_key   text,
+    last_error   text,
+    created_at   timestamptz NOT NULL DEFAULT now(),
+    finished_at  timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS pgxatomic_jobs_available_idx
+    ON pgxatomic_jobs (run_at, id)
+    WHERE state = 'available';
+
+CREATE UNIQUE INDEX IF NOT EXISTS pgxatomic_jobs_unique_idx
+    ON pgxatomic_jobs (kind, unique_key)
+    WHERE unique_key IS NOT NULL AND state = 'available';
@@ -0,0 +1,88 @@
+// Package queue implements job queue on top of pgxatomic.
+//
+// Jobs are enqueued with Enqueue in the same transaction as business data,
+// so job exists only if transaction commits, and processed by Worker.
+// Migration must be applied before use.
+package queue
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/ysomad/pgxatomic"
+)
+
+// Migration creates jobs table.
+//
+//go:embed migration.sql
+var Migration string
+
+// ErrDuplicateJob is returned by Enqueue if available job of the same kind
+// with the same unique key already exists.
+var ErrDuplicateJob = errors.New("queue: duplicate job")
+
+// Job states.
+const (
+	StateAvailable = "available"
+	StateCompleted = "completed"
+	StateDiscarded = "discarded"
+)
+
+const defaultMaxAttempts = 25
+
+// EnqueueOptions configures enqueued job.
+type EnqueueOptions struct {
+	// RunAt schedules job, it is available immediately if zero.
+	RunAt time.Time
+
+	// MaxAttempts after which job is discarded, 25 by default.
+	MaxAttempts int
+
+	// UniqueKey prevents enqueue of job while available job of the same kind
+	// with the same key exists.
+	UniqueKey string
+}
+
+// Enqueue inserts job with args marshaled to JSON using transaction from ctx,
+// pgxatomic.ErrNoTx is returned if there is none.
+func Enqueue(ctx context.Context, kind string, args any, opts EnqueueOptions) (int64, error) {
+	tx := pgxatomic.TxFromContext(ctx)
+	if tx == nil {
+		return 0, pgxatomic.ErrNoTx
+	}
+
+	b, err := json.Marshal(args)
+	if err != nil {
+		return 0, err
+	}
+
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultMaxAttempts
+	}
+
+	var (
+		runAt     *time.Time
+		uniqueKey *string
+	)
+	if !opts.RunAt.IsZero() {
+		runAt = &opts.RunAt
+	}
+	if opts.UniqueKey != "" {
+		uniqueKey = &opts.UniqueKey
+	}
+
+	var id int64
+	err = pgxatomic.QueryRow(ctx, tx, `INSERT INTO pgxatomic_jobs (kind, args, max_attempts, run_at, unique_key)
+VALUES ($1, $2, $3, coalesce($4, now()), $5)
+ON CONFLICT (kind, unique_key) WHERE unique_key IS NOT NULL AND state = 'available' DO NOTHING
+RETURNING id`, kind, b, opts.MaxAttempts, runAt, uniqueKey).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrDuplicateJob
+	}
+	return id, err
+}
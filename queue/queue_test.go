@@ -0,0 +1,300 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ysomad/pgxatomic"
+	"github.com/ysomad/pgxatomic/internal/pgtest"
+)
+
+type row struct {
+	id  int64
+	err error
+}
+
+func (r row) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	*dest[0].(*int64) = r.id
+	return nil
+}
+
+type queryRowTx struct {
+	pgx.Tx
+	row  row
+	args []any
+}
+
+func (tx *queryRowTx) QueryRow(_ context.Context, _ string, args ...any) pgx.Row {
+	tx.args = args
+	return tx.row
+}
+
+func TestEnqueue(t *testing.T) {
+	runAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		row      row
+		opts     EnqueueOptions
+		wantID   int64
+		wantErr  error
+		wantArgs []any
+	}{
+		{
+			name:     "defaults",
+			row:      row{id: 1},
+			wantID:   1,
+			wantArgs: []any{"email", []byte(`{"to":"a@b.c"}`), 25, (*time.Time)(nil), (*string)(nil)},
+		},
+		{
+			name:     "scheduled unique",
+			row:      row{id: 2},
+			opts:     EnqueueOptions{RunAt: runAt, MaxAttempts: 3, UniqueKey: "a@b.c"},
+			wantID:   2,
+			wantArgs: []any{"email", []byte(`{"to":"a@b.c"}`), 3, &runAt, ptr("a@b.c")},
+		},
+		{
+			name:    "duplicate",
+			row:     row{err: pgx.ErrNoRows},
+			opts:    EnqueueOptions{UniqueKey: "a@b.c"},
+			wantErr: ErrDuplicateJob,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := &queryRowTx{row: tt.row}
+			ctx := pgxatomic.WithTx(context.Background(), tx)
+
+			id, err := Enqueue(ctx, "email", map[string]string{"to": "a@b.c"}, tt.opts)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.wantID, id)
+			if tt.wantArgs != nil {
+				assert.Equal(t, tt.wantArgs, tx.args)
+			}
+		})
+	}
+
+	_, err := Enqueue(context.Background(), "email", nil, EnqueueOptions{})
+	assert.ErrorIs(t, err, pgxatomic.ErrNoTx)
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func testPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	pool := pgtest.Pool(t)
+
+	ctx := context.Background()
+	_, err := pool.Exec(ctx, Migration)
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, "TRUNCATE pgxatomic_jobs")
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, "CREATE TABLE IF NOT EXISTS pgxatomic_jobs_test (v text)")
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, "TRUNCATE pgxatomic_jobs_test")
+	require.NoError(t, err)
+
+	return pool
+}
+
+func TestWorker(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	runner, err := pgxatomic.NewRunner(pool, pgx.TxOptions{})
+	require.NoError(t, err)
+
+	var ids []int64
+	err = runner.Run(ctx, func(ctx context.Context) error {
+		for _, args := range []map[string]string{{"v": "ok"}, {"v": "flaky"}, {"v": "broken"}} {
+			id, err := Enqueue(ctx, "test", args, EnqueueOptions{MaxAttempts: 2})
+			if err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+		if _, err := Enqueue(ctx, "test", map[string]string{"v": "later"}, EnqueueOptions{RunAt: time.Now().Add(time.Hour)}); err != nil {
+			return err
+		}
+		if _, err := Enqueue(ctx, "test", map[string]string{"v": "unique"}, EnqueueOptions{UniqueKey: "k"}); err != nil {
+			return err
+		}
+		_, err := Enqueue(ctx, "test", map[string]string{"v": "unique"}, EnqueueOptions{UniqueKey: "k"})
+		assert.ErrorIs(t, err, ErrDuplicateJob)
+		return nil
+	})
+	require.NoError(t, err)
+
+	var flaky atomic.Int32
+	w := NewWorker(runner, Config{Backoff: func(int) time.Duration { return 0 }})
+	w.Handle("test", func(ctx context.Context, job Job) error {
+		var args map[string]string
+		if err := job.Decode(&args); err != nil {
+			return err
+		}
+		if _, err := pgxatomic.Exec(ctx, nil, "INSERT INTO pgxatomic_jobs_test (v) VALUES ($1)", args["v"]); err != nil {
+			return err
+		}
+		switch {
+		case args["v"] == "flaky" && flaky.Add(1) == 1, args["v"] == "broken":
+			return errors.New(args["v"])
+		}
+		return nil
+	})
+
+	for {
+		ok, err := w.Work(ctx)
+		require.NoError(t, err)
+		if !ok {
+			break
+		}
+	}
+
+	var states []string
+	err = collectStrings(ctx, pool, &states, "SELECT state || ':' || attempts FROM pgxatomic_jobs WHERE id = ANY($1) ORDER BY id", ids)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"completed:1", "completed:2", "discarded:2"}, states)
+
+	var written []string
+	err = collectStrings(ctx, pool, &written, "SELECT v FROM pgxatomic_jobs_test ORDER BY v")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"flaky", "ok", "unique"}, written, "changes of failed attempts must be rolled back")
+
+	var available int
+	require.NoError(t, pool.QueryRow(ctx, "SELECT count(*) FROM pgxatomic_jobs WHERE state = 'available'").Scan(&available))
+	assert.Equal(t, 1, available, "scheduled job must not be processed")
+}
+
+func TestWorker_Shutdown(t *testing.T) {
+	pool := testPool(t)
+
+	runner, err := pgxatomic.NewRunner(pool, pgx.TxOptions{})
+	require.NoError(t, err)
+
+	err = runner.Run(context.Background(), func(ctx context.Context) error {
+		_, err := Enqueue(ctx, "slow", nil, EnqueueOptions{})
+		return err
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	var finished atomic.Bool
+
+	w := NewWorker(runner, Config{Concurrency: 2, PollInterval: 10 * time.Millisecond})
+	w.Handle("slow", func(ctx context.Context, job Job) error {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		finished.Store(ctx.Err() == nil)
+		return nil
+	})
+
+	done := make(chan error)
+	go func() { done <- w.Run(ctx) }()
+
+	<-started
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+	assert.True(t, finished.Load(), "job in progress must finish with live context")
+
+	var state string
+	require.NoError(t, pool.QueryRow(context.Background(), "SELECT state FROM pgxatomic_jobs WHERE kind = 'slow'").Scan(&state))
+	assert.Equal(t, StateCompleted, state)
+}
+
+func TestWorker_JobTimeout(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	runner, err := pgxatomic.NewRunner(pool, pgx.TxOptions{})
+	require.NoError(t, err)
+
+	var id int64
+	err = runner.Run(ctx, func(ctx context.Context) error {
+		var err error
+		id, err = Enqueue(ctx, "hang", nil, EnqueueOptions{MaxAttempts: 2})
+		return err
+	})
+	require.NoError(t, err)
+
+	w := NewWorker(runner, Config{JobTimeout: 50 * time.Millisecond, Backoff: func(int) time.Duration { return 0 }})
+	w.Handle("hang", func(ctx context.Context, job Job) error {
+		<-ctx.Done()
+		return nil
+	})
+
+	state := func() string {
+		var s string
+		require.NoError(t, pool.QueryRow(ctx, "SELECT state || ':' || attempts FROM pgxatomic_jobs WHERE id = $1", id).Scan(&s))
+		return s
+	}
+
+	// attempt is counted although processing transaction is rolled back
+	ok, err := w.Work(ctx)
+	assert.True(t, ok)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, "available:1", state())
+
+	ok, err = w.Work(ctx)
+	assert.True(t, ok)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, "discarded:2", state())
+
+	var lastErr string
+	require.NoError(t, pool.QueryRow(ctx, "SELECT last_error FROM pgxatomic_jobs WHERE id = $1", id).Scan(&lastErr))
+	assert.Contains(t, lastErr, "deadline exceeded")
+
+	ok, err = w.Work(ctx)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestWorker_UnfinishedAttempt(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	runner, err := pgxatomic.NewRunner(pool, pgx.TxOptions{})
+	require.NoError(t, err)
+
+	// last attempt was claimed by worker which crashed
+	var id int64
+	require.NoError(t, pool.QueryRow(ctx,
+		"INSERT INTO pgxatomic_jobs (kind, args, attempts, max_attempts) VALUES ('crash', 'null', 3, 3) RETURNING id").Scan(&id))
+
+	w := NewWorker(runner, Config{})
+	w.Handle("crash", func(context.Context, Job) error {
+		t.Error("job with no attempts left must not be processed")
+		return nil
+	})
+
+	ok, err := w.Work(ctx)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	var state, lastErr string
+	require.NoError(t, pool.QueryRow(ctx, "SELECT state, last_error FROM pgxatomic_jobs WHERE id = $1", id).Scan(&state, &lastErr))
+	assert.Equal(t, StateDiscarded, state)
+	assert.Equal(t, errAttemptUnfinished.Error(), lastErr)
+}
+
+func collectStrings(ctx context.Context, pool *pgxpool.Pool, dest *[]string, sql string, args ...any) error {
+	rows, err := pool.Query(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+	*dest, err = pgx.CollectRows(rows, pgx.RowTo[string])
+	return err
+}
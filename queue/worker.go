@@ -0,0 +1,300 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/ysomad/pgxatomic"
+)
+
+// Job is claimed job passed to Handler.
+type Job struct {
+	ID          int64
+	Kind        string
+	Args        json.RawMessage
+	Attempt     int
+	MaxAttempts int
+	CreatedAt   time.Time
+}
+
+// Decode unmarshals job args into v.
+func (j Job) Decode(v any) error {
+	return json.Unmarshal(j.Args, v)
+}
+
+// Handler processes job inside transaction which marks it completed, its
+// changes are rolled back to savepoint if it returns error and job is
+// retried with backoff until max attempts are reached.
+type Handler func(ctx context.Context, job Job) error
+
+// Config configures Worker, zero values are replaced with defaults.
+type Config struct {
+	// Concurrency is number of jobs processed in parallel, 1 by default.
+	Concurrency int
+
+	// PollInterval is delay between polls when there are no jobs, 1s by default.
+	PollInterval time.Duration
+
+	// JobTimeout limits processing time of single job, unlimited if zero.
+	JobTimeout time.Duration
+
+	// Backoff returns delay before next attempt after attempt failed,
+	// pgxatomic.ExponentialBackoff is used by default.
+	Backoff func(attempt int) time.Duration
+
+	// OnError is called with errors of claiming and failed jobs.
+	OnError func(error)
+}
+
+// Worker claims jobs with FOR UPDATE SKIP LOCKED and processes them with
+// registered handlers, multiple workers may run concurrently.
+type Worker struct {
+	runner pgxatomic.TxRunner
+	cfg    Config
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+}
+
+func NewWorker(runner pgxatomic.TxRunner, cfg Config) *Worker {
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 1
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.Backoff == nil {
+		cfg.Backoff = pgxatomic.ExponentialBackoff
+	}
+	if cfg.OnError == nil {
+		cfg.OnError = func(error) {}
+	}
+	return &Worker{
+		runner:   runner,
+		cfg:      cfg,
+		handlers: make(map[string]Handler),
+	}
+}
+
+// Handle registers handler of jobs of kind, it must be called before Run.
+func (w *Worker) Handle(kind string, h Handler) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.handlers[kind] = h
+}
+
+// Run processes jobs until ctx is done, then waits for jobs in progress to
+// finish and returns. Jobs are processed with context which is not canceled
+// with ctx, use JobTimeout to limit shutdown time.
+func (w *Worker) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for range w.cfg.Concurrency {
+		wg.Go(func() {
+			w.loop(ctx)
+		})
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+func (w *Worker) loop(ctx context.Context) {
+	for ctx.Err() == nil {
+		ok, err := w.Work(ctx)
+		if err != nil {
+			w.cfg.OnError(err)
+		}
+		if ok && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(w.cfg.PollInterval):
+		}
+	}
+}
+
+func (w *Worker) kinds() []string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	kinds := make([]string, 0, len(w.handlers))
+	for k := range w.handlers {
+		kinds = append(kinds, k)
+	}
+	return kinds
+}
+
+func (w *Worker) handler(kind string) Handler {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.handlers[kind]
+}
+
+// Work claims and processes single job, false is returned if there is no
+// available job.
+//
+// Job is claimed in its own transaction which counts attempt and reschedules
+// job with backoff, so attempt is not lost if processing transaction rolls
+// back or worker crashes. Failure of processing transaction is recorded in
+// new transaction.
+func (w *Worker) Work(ctx context.Context) (bool, error) {
+	ctx = context.WithoutCancel(ctx)
+
+	job, ok, err := w.claim(ctx)
+	if err != nil || !ok {
+		return false, err
+	}
+	if job.Attempt > job.MaxAttempts {
+		// discarded by claim
+		return true, nil
+	}
+
+	jobCtx := ctx
+	if w.cfg.JobTimeout > 0 {
+		var cancel context.CancelFunc
+		jobCtx, cancel = context.WithTimeout(ctx, w.cfg.JobTimeout)
+		defer cancel()
+	}
+
+	err = w.runner.Run(jobCtx, func(ctx context.Context) error {
+		return w.process(ctx, job)
+	}, pgxatomic.WithTxName("queue_work"))
+	if err != nil {
+		return true, errors.Join(err, w.fail(ctx, job, err))
+	}
+	return true, nil
+}
+
+// errAttemptUnfinished is recorded for job discarded because its last attempt
+// was claimed but never finished.
+var errAttemptUnfinished = errors.New("queue: attempt did not finish")
+
+// claim locks available job, counts attempt and reschedules job with backoff.
+// Job whose attempts were used up by unfinished attempts is discarded instead.
+func (w *Worker) claim(ctx context.Context) (Job, bool, error) {
+	var (
+		j  Job
+		ok bool
+	)
+	err := w.runner.Run(ctx, func(ctx context.Context) error {
+		tx := pgxatomic.TxFromContext(ctx)
+
+		err := pgxatomic.QueryRow(ctx, tx, `SELECT id, kind, args, attempts + 1, max_attempts, created_at
+FROM pgxatomic_jobs
+WHERE state = 'available' AND run_at <= now() AND kind = ANY($1)
+ORDER BY run_at, id
+LIMIT 1
+FOR UPDATE SKIP LOCKED`, w.kinds()).
+			Scan(&j.ID, &j.Kind, &j.Args, &j.Attempt, &j.MaxAttempts, &j.CreatedAt)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		ok = true
+
+		if j.Attempt > j.MaxAttempts {
+			_, err = pgxatomic.Exec(ctx, tx,
+				"UPDATE pgxatomic_jobs SET state = 'discarded', finished_at = now(), last_error = $2 WHERE id = $1",
+				j.ID, errAttemptUnfinished.Error())
+			return err
+		}
+
+		_, err = pgxatomic.Exec(ctx, tx,
+			"UPDATE pgxatomic_jobs SET attempts = $2, run_at = now() + $3::interval WHERE id = $1",
+			j.ID, j.Attempt, w.cfg.Backoff(j.Attempt))
+		return err
+	}, pgxatomic.WithTxName("queue_claim"))
+	return j, ok, err
+}
+
+// process locks claimed job and runs its handler, job is skipped if it was
+// claimed again since.
+func (w *Worker) process(ctx context.Context, job Job) error {
+	tx := pgxatomic.TxFromContext(ctx)
+
+	var locked bool
+	err := pgxatomic.QueryRow(ctx, tx,
+		"SELECT true FROM pgxatomic_jobs WHERE id = $1 AND attempts = $2 AND state = 'available' FOR UPDATE",
+		job.ID, job.Attempt).Scan(&locked)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	jobErr := w.handle(ctx, tx, job)
+	if jobErr == nil {
+		_, err := pgxatomic.Exec(ctx, tx,
+			"UPDATE pgxatomic_jobs SET state = 'completed', finished_at = now() WHERE id = $1", job.ID)
+		return err
+	}
+
+	w.cfg.OnError(fmt.Errorf("queue: job %d of kind %s attempt %d: %w", job.ID, job.Kind, job.Attempt, jobErr))
+
+	if job.Attempt >= job.MaxAttempts {
+		_, err := pgxatomic.Exec(ctx, tx,
+			"UPDATE pgxatomic_jobs SET state = 'discarded', finished_at = now(), last_error = $2 WHERE id = $1",
+			job.ID, jobErr.Error())
+		return err
+	}
+
+	_, err = pgxatomic.Exec(ctx, tx,
+		"UPDATE pgxatomic_jobs SET run_at = now() + $2::interval, last_error = $3 WHERE id = $1",
+		job.ID, w.cfg.Backoff(job.Attempt), jobErr.Error())
+	return err
+}
+
+// failTimeout limits recording of failed processing transaction, since
+// context of job may be expired.
+const failTimeout = 5 * time.Second
+
+// fail records cause of rolled back processing transaction, job keeps backoff
+// set by claim and is discarded if it has no attempts left.
+func (w *Worker) fail(ctx context.Context, job Job, cause error) error {
+	ctx, cancel := context.WithTimeout(ctx, failTimeout)
+	defer cancel()
+
+	return w.runner.Run(ctx, func(ctx context.Context) error {
+		_, err := pgxatomic.Exec(ctx, pgxatomic.TxFromContext(ctx), `UPDATE pgxatomic_jobs
+SET last_error = $3,
+	state = CASE WHEN attempts >= max_attempts THEN 'discarded' ELSE state END,
+	finished_at = CASE WHEN attempts >= max_attempts THEN now() END
+WHERE id = $1 AND attempts = $2 AND state = 'available'`, job.ID, job.Attempt, cause.Error())
+		return err
+	}, pgxatomic.WithTxName("queue_fail"))
+}
+
+// handle runs handler in savepoint, so its changes are rolled back if it fails.
+func (w *Worker) handle(ctx context.Context, tx pgx.Tx, job Job) (err error) {
+	h := w.handler(job.Kind)
+	if h == nil {
+		return fmt.Errorf("queue: no handler of kind %s", job.Kind)
+	}
+
+	sp, err := tx.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = sp.Rollback(ctx)
+			err = fmt.Errorf("queue: panic: %v", p)
+		}
+	}()
+
+	if err := h(pgxatomic.WithTx(ctx, sp), job); err != nil {
+		_ = sp.Rollback(ctx)
+		return err
+	}
+	return sp.Commit(ctx)
+}
@@ -0,0 +1,102 @@
+package pgxatomic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/ysomad/pgxatomic/pgerr"
+)
+
+// ErrLockNotAvailable is returned if row lock could not be obtained
+// immediately with NOWAIT or before lock_timeout expired.
+var ErrLockNotAvailable = errors.New("pgxatomic: lock not available")
+
+// LockMode is row locking clause appended to SELECT.
+type LockMode string
+
+const (
+	ForUpdate      LockMode = "FOR UPDATE"
+	ForNoKeyUpdate LockMode = "FOR NO KEY UPDATE"
+	ForShare       LockMode = "FOR SHARE"
+	ForKeyShare    LockMode = "FOR KEY SHARE"
+)
+
+// NoWait makes lock fail with ErrLockNotAvailable instead of waiting for
+// rows locked by other transactions.
+func (m LockMode) NoWait() LockMode { return m + " NOWAIT" }
+
+// SkipLocked makes lock skip rows locked by other transactions.
+func (m LockMode) SkipLocked() LockMode { return m + " SKIP LOCKED" }
+
+// LockOne locks rows selected by sql with mode using transaction from ctx
+// and returns the first one, ErrNoTx is returned if there is none since lock
+// would be released immediately. Single column is scanned into T as with
+// pgx.RowTo, so T may be time.Time, pgtype.Text or other struct type pgx
+// scans from one value; several columns are scanned into struct T by names
+// as with pgx.RowToStructByName. pgx.ErrNoRows is returned if no rows are
+// selected.
+func LockOne[T any](ctx context.Context, db querier, sql string, mode LockMode, args ...any) (T, error) {
+	rows, err := lockRows(ctx, db, sql, mode, args)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	v, err := pgx.CollectOneRow(rows, rowTo[T])
+	return v, lockError(err)
+}
+
+// LockAll locks and returns all rows selected by sql with mode, see LockOne.
+func LockAll[T any](ctx context.Context, db querier, sql string, mode LockMode, args ...any) ([]T, error) {
+	rows, err := lockRows(ctx, db, sql, mode, args)
+	if err != nil {
+		return nil, err
+	}
+	v, err := pgx.CollectRows(rows, rowTo[T])
+	return v, lockError(err)
+}
+
+func lockRows(ctx context.Context, db querier, sql string, mode LockMode, args []any) (pgx.Rows, error) {
+	if TxFromContext(ctx) == nil {
+		return nil, ErrNoTx
+	}
+	sql = strings.TrimRight(strings.TrimSpace(sql), ";") + " " + string(mode)
+	rows, err := Query(ctx, db, sql, args...)
+	return rows, lockError(err)
+}
+
+func rowTo[T any](row pgx.CollectableRow) (T, error) {
+	if len(row.FieldDescriptions()) == 1 {
+		return pgx.RowTo[T](row)
+	}
+	return pgx.RowToStructByName[T](row)
+}
+
+// lockError wraps lock_not_available error with ErrLockNotAvailable.
+func lockError(err error) error {
+	if pgerr.IsLockNotAvailable(err) {
+		return fmt.Errorf("%w: %w", ErrLockNotAvailable, err)
+	}
+	return err
+}
+
+// SetLockTimeout sets lock_timeout for the rest of transaction from ctx,
+// ErrNoTx is returned if there is none. Waiting for lock longer than d fails
+// with ErrLockNotAvailable.
+func SetLockTimeout(ctx context.Context, d time.Duration) error {
+	tx := TxFromContext(ctx)
+	if tx == nil {
+		return ErrNoTx
+	}
+	// zero disables timeout, so round up sub-millisecond durations
+	ms := d.Milliseconds()
+	if d > 0 && ms == 0 {
+		ms = 1
+	}
+	_, err := Exec(ctx, tx, "SELECT set_config('lock_timeout', $1, true)", fmt.Sprintf("%dms", ms))
+	return err
+}
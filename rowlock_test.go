@@ -0,0 +1,197 @@
+package pgxatomic
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestLockMode(t *testing.T) {
+	assert.Equal(t, LockMode("FOR UPDATE NOWAIT"), ForUpdate.NoWait())
+	assert.Equal(t, LockMode("FOR NO KEY UPDATE SKIP LOCKED"), ForNoKeyUpdate.SkipLocked())
+}
+
+func TestLockOne(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tx := NewMockTx(ctrl)
+	rows := NewMockRows(ctrl)
+
+	tx.EXPECT().Query(gomock.Any(), "SELECT id FROM jobs WHERE state = $1 LIMIT 1 FOR UPDATE SKIP LOCKED", "pending").Return(rows, nil)
+	rows.EXPECT().FieldDescriptions().Return([]pgconn.FieldDescription{{Name: "id"}}).AnyTimes()
+	gomock.InOrder(
+		rows.EXPECT().Next().Return(true),
+		rows.EXPECT().Scan(gomock.Any()).DoAndReturn(func(dest ...any) error {
+			*dest[0].(*int64) = 42
+			return nil
+		}),
+	)
+	rows.EXPECT().Close().Times(2)
+	rows.EXPECT().Err().Return(nil)
+
+	id, err := LockOne[int64](WithTx(context.Background(), tx), nil,
+		"SELECT id FROM jobs WHERE state = $1 LIMIT 1;", ForUpdate.SkipLocked(), "pending")
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), id)
+}
+
+// lockOneColumn locks single row of single column and scans it into T.
+func lockOneColumn[T any](t *testing.T, v T) (T, error) {
+	t.Helper()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tx := NewMockTx(ctrl)
+	rows := NewMockRows(ctrl)
+
+	tx.EXPECT().Query(gomock.Any(), "SELECT v FROM t FOR UPDATE").Return(rows, nil)
+	rows.EXPECT().FieldDescriptions().Return([]pgconn.FieldDescription{{Name: "v"}}).AnyTimes()
+	gomock.InOrder(
+		rows.EXPECT().Next().Return(true),
+		rows.EXPECT().Scan(gomock.Any()).DoAndReturn(func(dest ...any) error {
+			*dest[0].(*T) = v
+			return nil
+		}),
+	)
+	rows.EXPECT().Close().Times(2)
+	rows.EXPECT().Err().Return(nil)
+
+	return LockOne[T](WithTx(context.Background(), tx), nil, "SELECT v FROM t", ForUpdate)
+}
+
+func TestLockOne_SingleColumnStruct(t *testing.T) {
+	t.Run("time", func(t *testing.T) {
+		want := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+		got, err := lockOneColumn(t, want)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("text", func(t *testing.T) {
+		want := pgtype.Text{String: "pending", Valid: true}
+		got, err := lockOneColumn(t, want)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("numeric", func(t *testing.T) {
+		want := pgtype.Numeric{Int: big.NewInt(12345), Exp: -2, Valid: true}
+		got, err := lockOneColumn(t, want)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("uuid", func(t *testing.T) {
+		want := pgtype.UUID{Bytes: [16]byte{1, 2, 3}, Valid: true}
+		got, err := lockOneColumn(t, want)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+}
+
+func TestLockAll(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	type account struct {
+		ID      int64
+		Balance int64
+	}
+
+	tx := NewMockTx(ctrl)
+	rows := NewMockRows(ctrl)
+
+	tx.EXPECT().Query(gomock.Any(), "SELECT id, balance FROM accounts FOR SHARE").Return(rows, nil)
+	rows.EXPECT().FieldDescriptions().Return([]pgconn.FieldDescription{{Name: "id"}, {Name: "balance"}}).AnyTimes()
+	gomock.InOrder(
+		rows.EXPECT().Next().Return(true),
+		rows.EXPECT().Scan(gomock.Any(), gomock.Any()).DoAndReturn(func(dest ...any) error {
+			*dest[0].(*int64), *dest[1].(*int64) = 1, 100
+			return nil
+		}),
+		rows.EXPECT().Next().Return(false),
+		rows.EXPECT().Err().Return(nil),
+	)
+	rows.EXPECT().Close()
+
+	got, err := LockAll[account](WithTx(context.Background(), tx), nil, "SELECT id, balance FROM accounts", ForShare)
+	require.NoError(t, err)
+	assert.Equal(t, []account{{ID: 1, Balance: 100}}, got)
+}
+
+func TestLockOne_Errors(t *testing.T) {
+	t.Run("no tx", func(t *testing.T) {
+		_, err := LockOne[int64](context.Background(), nil, "SELECT 1", ForUpdate)
+		assert.ErrorIs(t, err, ErrNoTx)
+	})
+
+	t.Run("lock not available", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		pgErr := &pgconn.PgError{Code: "55P03", Message: "could not obtain lock on row"}
+
+		tx := NewMockTx(ctrl)
+		rows := NewMockRows(ctrl)
+		tx.EXPECT().Query(gomock.Any(), "SELECT id FROM jobs FOR UPDATE NOWAIT").Return(rows, nil)
+		rows.EXPECT().Next().Return(false)
+		rows.EXPECT().Err().Return(pgErr)
+		rows.EXPECT().Close()
+
+		_, err := LockOne[int64](WithTx(context.Background(), tx), nil, "SELECT id FROM jobs", ForUpdate.NoWait())
+		assert.ErrorIs(t, err, ErrLockNotAvailable)
+		assert.ErrorIs(t, err, pgErr)
+	})
+
+	t.Run("no rows", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		tx := NewMockTx(ctrl)
+		rows := NewMockRows(ctrl)
+		tx.EXPECT().Query(gomock.Any(), gomock.Any()).Return(rows, nil)
+		rows.EXPECT().Next().Return(false)
+		rows.EXPECT().Err().Return(nil)
+		rows.EXPECT().Close()
+
+		_, err := LockOne[int64](WithTx(context.Background(), tx), nil, "SELECT id FROM jobs", ForUpdate)
+		assert.ErrorIs(t, err, pgx.ErrNoRows)
+		assert.NotErrorIs(t, err, ErrLockNotAvailable)
+	})
+}
+
+func TestSetLockTimeout(t *testing.T) {
+	tests := []struct {
+		name string
+		d    time.Duration
+		want string
+	}{
+		{name: "seconds", d: 2 * time.Second, want: "2000ms"},
+		{name: "sub millisecond", d: time.Microsecond, want: "1ms"},
+		{name: "disabled", d: 0, want: "0ms"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			tx := NewMockTx(ctrl)
+			tx.EXPECT().Exec(gomock.Any(), "SELECT set_config('lock_timeout', $1, true)", tt.want).Return(pgconn.CommandTag{}, nil)
+
+			assert.NoError(t, SetLockTimeout(WithTx(context.Background(), tx), tt.d))
+		})
+	}
+
+	assert.ErrorIs(t, SetLockTimeout(context.Background(), time.Second), ErrNoTx)
+}
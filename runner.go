@@ -3,35 +3,283 @@ package pgxatomic
 import (
 	"context"
 	"errors"
+	"fmt"
+	"log/slog"
+	"time"
 
 	"github.com/jackc/pgx/v5"
-)
 
-type txStarter interface {
-	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
-}
+	"github.com/ysomad/pgxatomic/pgerr"
+)
 
 // Runner starts transaction in Run method by wrapping txFunc using db,
 // pgx.Conn and pgxpool.Pool implements db.
 type Runner struct {
-	db   txStarter
+	db   TxStarter
 	opts pgx.TxOptions
+
+	maxAttempts int
+	retryable   func(error) bool
+	metrics     Metrics
+	logger      *txLogger
+	appName     func(TxInfo) string
+	statusDB    queryRower
+	propagation Propagation
+
+	interceptors         []Interceptor
+	boundaryInterceptors []Interceptor
 }
 
-func NewRunner(db txStarter, opts pgx.TxOptions) (Runner, error) {
+// RunnerOption configures Runner.
+type RunnerOption func(*Runner)
+
+// WithRetry makes Run execute txFunc in a new transaction up to maxAttempts
+// times while it fails with retryable error.
+func WithRetry(maxAttempts int) RunnerOption {
+	return func(r *Runner) {
+		r.maxAttempts = maxAttempts
+	}
+}
+
+// WithRetryIf overrides predicate used to decide whether failed transaction
+// should be retried, pgerr.IsRetryable is used by default.
+func WithRetryIf(retryable func(error) bool) RunnerOption {
+	return func(r *Runner) {
+		r.retryable = retryable
+	}
+}
+
+// WithMetrics sets hook receiving transaction lifecycle events.
+func WithMetrics(m Metrics) RunnerOption {
+	return func(r *Runner) {
+		r.metrics = m
+	}
+}
+
+// WithLogger logs transaction lifecycle events to l.
+func WithLogger(l *slog.Logger, opts LogOptions) RunnerOption {
+	return func(r *Runner) {
+		r.logger = newTxLogger(l, opts)
+	}
+}
+
+// WithApplicationName sets application_name for the duration of each
+// transaction, so it is visible in pg_stat_activity. DefaultApplicationName
+// is used if format is nil.
+func WithApplicationName(format func(TxInfo) string) RunnerOption {
+	if format == nil {
+		format = DefaultApplicationName
+	}
+	return func(r *Runner) {
+		r.appName = format
+	}
+}
+
+// DefaultApplicationName formats application_name as "pgxatomic:name:id".
+func DefaultApplicationName(info TxInfo) string {
+	return "pgxatomic:" + info.Name + ":" + info.ID
+}
+
+func NewRunner(db TxStarter, opts pgx.TxOptions, ropts ...RunnerOption) (Runner, error) {
 	if db == nil {
 		return Runner{}, errors.New("pgxatomic: db cannot be nil")
 	}
 
-	return Runner{
+	r := Runner{
 		db:   db,
 		opts: opts,
-	}, nil
+	}
+	for _, o := range ropts {
+		o(&r)
+	}
+
+	return r, nil
+}
+
+// RunOption configures single Run call.
+type RunOption func(*runOptions)
+
+type runOptions struct {
+	name string
+}
+
+// WithTxName sets transaction name used to label metrics and logs.
+func WithTxName(name string) RunOption {
+	return func(o *runOptions) {
+		o.name = name
+	}
+}
+
+// Run wraps txFunc in transaction with injected pgx.Tx into context and runs it.
+// Transaction is committed if txFunc returns nil and rolled back otherwise,
+// *CommitError is returned if commit fails and *TxError if rollback fails.
+// If ctx already carries transaction, Run behaves as set by WithPropagation.
+func (r Runner) Run(ctx context.Context, txFunc func(ctx context.Context) error, opts ...RunOption) error {
+	var ro runOptions
+	for _, o := range opts {
+		o(&ro)
+	}
+
+	info := TxInfo{
+		Name:    ro.name,
+		ID:      newTxID(),
+		Options: r.opts,
+	}
+	if parent, ok := TxInfoFromContext(ctx); ok {
+		info.Depth = parent.Depth + 1
+	}
+
+	if parent := TxFromContext(ctx); parent != nil {
+		switch r.propagationFor(ctx) {
+		case PropagationJoin:
+			return chain(r.interceptors, info, txFunc)(ctx)
+		case PropagationNested:
+			info.Attempt = 1
+			return r.runNested(ctx, parent, &txState{info: info}, txFunc)
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		info.Attempt = attempt
+		st := &txState{info: info}
+
+		err := chain(r.boundaryInterceptors, info, func(ctx context.Context) error {
+			return r.run(ctx, st, txFunc)
+		})(ctx)
+		if err == nil || attempt >= r.maxAttempts || ctx.Err() != nil || !r.isRetryable(err) {
+			return err
+		}
+		r.hook().TxRetried(info.Name)
+		r.logger.retry(ctx, st, attempt+1, err)
+	}
 }
 
-// Run wraps txFunc in pgx.BeginTxFunc with injected pgx.Tx into context and runs it.
-func (r Runner) Run(ctx context.Context, txFunc func(ctx context.Context) error) error {
-	return pgx.BeginTxFunc(ctx, r.db, r.opts, func(tx pgx.Tx) error {
-		return txFunc(WithTx(ctx, tx))
-	})
+func (r Runner) run(ctx context.Context, st *txState, txFunc func(ctx context.Context) error) error {
+	m := r.hook()
+	st.statements.Store(0)
+
+	tx, err := r.db.BeginTx(ctx, r.opts)
+	if err != nil {
+		m.TxBeginFailed(st.info.Name)
+		r.logger.beginFailed(ctx, st, err)
+		return err
+	}
+
+	st.info.StartedAt = time.Now()
+	m.TxStarted(st.info.Name)
+	r.logger.begin(ctx, st)
+
+	rolledBack := func(cause error) {
+		d := time.Since(st.info.StartedAt)
+		m.TxRolledBack(st.info.Name, d, st.statementCount())
+		r.logger.rollback(ctx, st, d, cause)
+	}
+
+	abort := func(op string, cause error) error {
+		if rbErr := rollback(ctx, tx); rbErr != nil && !errors.Is(rbErr, pgx.ErrTxClosed) {
+			cause = &TxError{Op: op, Cause: cause, RollbackErr: rbErr}
+		}
+		rolledBack(cause)
+		return cause
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = abort(OpPanic, fmt.Errorf("pgxatomic: panic: %v", p))
+			panic(p)
+		}
+	}()
+
+	if r.appName != nil {
+		if _, err := tx.Exec(ctx, "SELECT set_config('application_name', $1, true)", r.appName(st.info)); err != nil {
+			return abort(OpSetup, err)
+		}
+	}
+
+	if err := chain(r.interceptors, st.info, txFunc)(withTxState(WithTx(ctx, tx), st)); err != nil {
+		return abort(OpTxFunc, err)
+	}
+
+	xid, err := r.currentXID(ctx, tx)
+	if err != nil {
+		return abort(OpSetup, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		if err = r.commitError(ctx, err, xid); err != nil {
+			rolledBack(err)
+			return err
+		}
+	}
+
+	d := time.Since(st.info.StartedAt)
+	m.TxCommitted(st.info.Name, d, st.statementCount())
+	r.logger.commit(ctx, st, d)
+	return nil
+}
+
+// runNested runs txFunc in savepoint of parent transaction. Savepoint is
+// released on success, release error is returned as is since parent
+// transaction decides the final outcome.
+func (r Runner) runNested(ctx context.Context, parent pgx.Tx, st *txState, txFunc func(ctx context.Context) error) error {
+	sp, err := parent.Begin(ctx)
+	if err != nil {
+		r.logger.beginFailed(ctx, st, err)
+		return err
+	}
+
+	st.info.StartedAt = time.Now()
+	r.logger.begin(ctx, st)
+
+	abort := func(op string, cause error) error {
+		if rbErr := rollback(ctx, sp); rbErr != nil && !errors.Is(rbErr, pgx.ErrTxClosed) {
+			cause = &TxError{Op: op, Cause: cause, RollbackErr: rbErr}
+		}
+		r.logger.rollback(ctx, st, time.Since(st.info.StartedAt), cause)
+		return cause
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = abort(OpPanic, fmt.Errorf("pgxatomic: panic: %v", p))
+			panic(p)
+		}
+	}()
+
+	if err := chain(r.interceptors, st.info, txFunc)(withTxState(WithTx(ctx, sp), st)); err != nil {
+		return abort(OpTxFunc, err)
+	}
+
+	if err := sp.Commit(ctx); err != nil {
+		r.logger.rollback(ctx, st, time.Since(st.info.StartedAt), err)
+		return err
+	}
+
+	r.logger.commit(ctx, st, time.Since(st.info.StartedAt))
+	return nil
+}
+
+// rollbackTimeout limits rollback, which is not canceled with context of Run
+// so transaction canceled by caller is rolled back instead of pgx closing
+// connection and reporting rollback failure.
+const rollbackTimeout = 5 * time.Second
+
+func rollback(ctx context.Context, tx pgx.Tx) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), rollbackTimeout)
+	defer cancel()
+	return tx.Rollback(ctx)
+}
+
+func (r Runner) hook() Metrics {
+	if r.metrics == nil {
+		return noopMetrics{}
+	}
+	return r.metrics
+}
+
+func (r Runner) isRetryable(err error) bool {
+	if r.retryable != nil {
+		return r.retryable(err)
+	}
+	return pgerr.IsRetryable(err)
 }
@@ -4,8 +4,10 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
 )
@@ -16,10 +18,10 @@ func TestNewRunner(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	mockDB := NewMocktxStarter(ctrl)
+	mockDB := NewMockTxStarter(ctrl)
 
 	type args struct {
-		db   txStarter
+		db   TxStarter
 		opts pgx.TxOptions
 	}
 
@@ -68,7 +70,7 @@ func TestRun_Commit(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	mockDB := NewMocktxStarter(ctrl)
+	mockDB := NewMockTxStarter(ctrl)
 	mockTx := NewMockTx(ctrl)
 
 	mockDB.EXPECT().BeginTx(gomock.Any(), gomock.Any()).Return(mockTx, nil)
@@ -90,7 +92,7 @@ func TestRun_Rollback(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	mockDB := NewMocktxStarter(ctrl)
+	mockDB := NewMockTxStarter(ctrl)
 	mockTx := NewMockTx(ctrl)
 
 	mockDB.EXPECT().BeginTx(gomock.Any(), gomock.Any()).Return(mockTx, nil)
@@ -109,7 +111,7 @@ func TestRun_BeginError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	mockDB := NewMocktxStarter(ctrl)
+	mockDB := NewMockTxStarter(ctrl)
 
 	mockDB.EXPECT().BeginTx(gomock.Any(), gomock.Any()).Return(nil, errTest)
 
@@ -121,3 +123,254 @@ func TestRun_BeginError(t *testing.T) {
 	})
 	assert.Error(t, err)
 }
+
+type recordedMetrics struct {
+	events     []string
+	statements []int
+}
+
+func (m *recordedMetrics) TxStarted(name string) {
+	m.events = append(m.events, "started:"+name)
+}
+
+func (m *recordedMetrics) TxCommitted(name string, _ time.Duration, statements int) {
+	m.events = append(m.events, "committed:"+name)
+	m.statements = append(m.statements, statements)
+}
+
+func (m *recordedMetrics) TxRolledBack(name string, _ time.Duration, statements int) {
+	m.events = append(m.events, "rolled_back:"+name)
+	m.statements = append(m.statements, statements)
+}
+
+func (m *recordedMetrics) TxRetried(name string) {
+	m.events = append(m.events, "retried:"+name)
+}
+
+func (m *recordedMetrics) TxBeginFailed(name string) {
+	m.events = append(m.events, "begin_failed:"+name)
+}
+
+func TestRun_Metrics(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := NewMockTxStarter(ctrl)
+	mockTx := NewMockTx(ctrl)
+	metrics := &recordedMetrics{}
+
+	mockDB.EXPECT().BeginTx(gomock.Any(), gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().Exec(gomock.Any(), "UPDATE balance SET amount = 0").Return(pgconn.CommandTag{}, nil).Times(2)
+	mockTx.EXPECT().Commit(gomock.Any()).Return(nil)
+
+	runner, err := NewRunner(mockDB, pgx.TxOptions{}, WithMetrics(metrics))
+	assert.NoError(t, err)
+
+	err = runner.Run(context.Background(), func(ctx context.Context) error {
+		_, _ = Exec(ctx, nil, "UPDATE balance SET amount = 0")
+		_, _ = Exec(ctx, nil, "UPDATE balance SET amount = 0")
+		return nil
+	}, WithTxName("withdraw"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"started:withdraw", "committed:withdraw"}, metrics.events)
+	assert.Equal(t, []int{2}, metrics.statements)
+}
+
+func TestRun_MetricsBeginFailed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := NewMockTxStarter(ctrl)
+	metrics := &recordedMetrics{}
+
+	mockDB.EXPECT().BeginTx(gomock.Any(), gomock.Any()).Return(nil, errTest)
+
+	runner, err := NewRunner(mockDB, pgx.TxOptions{}, WithMetrics(metrics))
+	assert.NoError(t, err)
+
+	err = runner.Run(context.Background(), func(ctx context.Context) error {
+		return nil
+	}, WithTxName("withdraw"))
+	assert.ErrorIs(t, err, errTest)
+	assert.Equal(t, []string{"begin_failed:withdraw"}, metrics.events)
+}
+
+func TestRun_Retry(t *testing.T) {
+	serializationErr := &pgconn.PgError{Code: "40001"}
+
+	tests := []struct {
+		name        string
+		opts        []RunnerOption
+		errs        []error
+		wantCalls   int
+		wantErr     error
+		wantRetries int
+	}{
+		{
+			name:      "retry disabled",
+			errs:      []error{serializationErr},
+			wantCalls: 1,
+			wantErr:   serializationErr,
+		},
+		{
+			name:        "retried until success",
+			opts:        []RunnerOption{WithRetry(3)},
+			errs:        []error{serializationErr, serializationErr, nil},
+			wantCalls:   3,
+			wantRetries: 2,
+		},
+		{
+			name:        "attempts exhausted",
+			opts:        []RunnerOption{WithRetry(2)},
+			errs:        []error{serializationErr, serializationErr},
+			wantCalls:   2,
+			wantErr:     serializationErr,
+			wantRetries: 1,
+		},
+		{
+			name:      "not retryable",
+			opts:      []RunnerOption{WithRetry(3)},
+			errs:      []error{errTest},
+			wantCalls: 1,
+			wantErr:   errTest,
+		},
+		{
+			name:        "custom predicate",
+			opts:        []RunnerOption{WithRetry(3), WithRetryIf(func(err error) bool { return errors.Is(err, errTest) })},
+			errs:        []error{errTest, nil},
+			wantCalls:   2,
+			wantRetries: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockDB := NewMockTxStarter(ctrl)
+			mockTx := NewMockTx(ctrl)
+			metrics := &recordedMetrics{}
+
+			mockDB.EXPECT().BeginTx(gomock.Any(), gomock.Any()).Return(mockTx, nil).Times(tt.wantCalls)
+			mockTx.EXPECT().Commit(gomock.Any()).Return(nil).AnyTimes()
+			mockTx.EXPECT().Rollback(gomock.Any()).Return(nil).AnyTimes()
+
+			runner, err := NewRunner(mockDB, pgx.TxOptions{}, append(tt.opts, WithMetrics(metrics))...)
+			assert.NoError(t, err)
+
+			calls := 0
+			err = runner.Run(context.Background(), func(ctx context.Context) error {
+				err := tt.errs[calls]
+				calls++
+				return err
+			})
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantCalls, calls)
+
+			retries := 0
+			for _, e := range metrics.events {
+				if e == "retried:" {
+					retries++
+				}
+			}
+			assert.Equal(t, tt.wantRetries, retries)
+		})
+	}
+}
+
+func TestRun_TxInfo(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := NewMockTxStarter(ctrl)
+	mockTx := NewMockTx(ctrl)
+	opts := pgx.TxOptions{IsoLevel: pgx.Serializable}
+
+	mockDB.EXPECT().BeginTx(gomock.Any(), opts).Return(mockTx, nil).Times(3)
+	mockTx.EXPECT().Rollback(gomock.Any()).Return(nil)
+	mockTx.EXPECT().Commit(gomock.Any()).Return(nil).Times(2)
+
+	runner, err := NewRunner(mockDB, opts, WithRetry(2))
+	assert.NoError(t, err)
+
+	var infos []TxInfo
+	err = runner.Run(context.Background(), func(ctx context.Context) error {
+		info, ok := TxInfoFromContext(ctx)
+		assert.True(t, ok)
+		infos = append(infos, info)
+
+		if info.Attempt == 1 {
+			return &pgconn.PgError{Code: "40P01"}
+		}
+
+		return runner.Run(ctx, func(ctx context.Context) error {
+			info, ok := TxInfoFromContext(ctx)
+			assert.True(t, ok)
+			infos = append(infos, info)
+			return nil
+		}, WithTxName("inner"))
+	}, WithTxName("outer"))
+	assert.NoError(t, err)
+
+	if assert.Len(t, infos, 3) {
+		assert.Equal(t, "outer", infos[0].Name)
+		assert.Equal(t, 1, infos[0].Attempt)
+		assert.Equal(t, 0, infos[0].Depth)
+		assert.Equal(t, opts, infos[0].Options)
+		assert.False(t, infos[0].StartedAt.IsZero())
+		assert.NotEmpty(t, infos[0].ID)
+
+		assert.Equal(t, infos[0].ID, infos[1].ID)
+		assert.Equal(t, 2, infos[1].Attempt)
+
+		assert.Equal(t, "inner", infos[2].Name)
+		assert.Equal(t, 1, infos[2].Depth)
+		assert.NotEqual(t, infos[0].ID, infos[2].ID)
+	}
+
+	_, ok := TxInfoFromContext(context.Background())
+	assert.False(t, ok)
+}
+
+func TestRun_ApplicationName(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := NewMockTxStarter(ctrl)
+	mockTx := NewMockTx(ctrl)
+
+	mockDB.EXPECT().BeginTx(gomock.Any(), gomock.Any()).Return(mockTx, nil).Times(2)
+
+	runner, err := NewRunner(mockDB, pgx.TxOptions{}, WithApplicationName(nil))
+	assert.NoError(t, err)
+
+	var gotName string
+	mockTx.EXPECT().Exec(gomock.Any(), "SELECT set_config('application_name', $1, true)", gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, args ...any) (pgconn.CommandTag, error) {
+			gotName = args[0].(string)
+			return pgconn.CommandTag{}, nil
+		})
+	mockTx.EXPECT().Commit(gomock.Any()).Return(nil)
+
+	var info TxInfo
+	err = runner.Run(context.Background(), func(ctx context.Context) error {
+		info, _ = TxInfoFromContext(ctx)
+		return nil
+	}, WithTxName("withdraw"))
+	assert.NoError(t, err)
+	assert.Equal(t, "pgxatomic:withdraw:"+info.ID, gotName)
+
+	mockTx.EXPECT().Exec(gomock.Any(), gomock.Any(), gomock.Any()).Return(pgconn.CommandTag{}, errTest)
+	mockTx.EXPECT().Rollback(gomock.Any()).Return(nil)
+
+	err = runner.Run(context.Background(), func(ctx context.Context) error {
+		t.Fatal("txFunc must not be called")
+		return nil
+	})
+	assert.ErrorIs(t, err, errTest)
+}
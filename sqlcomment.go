@@ -0,0 +1,137 @@
+package pgxatomic
+
+import (
+	"context"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// CommentTag returns key and value of sqlcommenter tag, ok is false if tag
+// must be omitted from comment.
+type CommentTag func(ctx context.Context) (key, value string, ok bool)
+
+// TxNameTag tags statement with name of transaction from context as "tx_name".
+func TxNameTag() CommentTag {
+	return func(ctx context.Context) (string, string, bool) {
+		info, ok := TxInfoFromContext(ctx)
+		if !ok || info.Name == "" {
+			return "", "", false
+		}
+		return "tx_name", info.Name, true
+	}
+}
+
+// TxIDTag tags statement with ID of transaction from context as "tx_id".
+// ID differs in every transaction, so it makes SQL text of every statement
+// unique: with default pgx.QueryExecModeCacheStatement each statement is
+// prepared again and evicts others from statement cache. Use it with
+// pgx.QueryExecModeDescribeExec or pgx.QueryExecModeSimpleProtocol.
+func TxIDTag() CommentTag {
+	return func(ctx context.Context) (string, string, bool) {
+		info, ok := TxInfoFromContext(ctx)
+		if !ok {
+			return "", "", false
+		}
+		return "tx_id", info.ID, true
+	}
+}
+
+// StaticTag tags every statement with the same value, for example service name.
+func StaticTag(key, value string) CommentTag {
+	return func(context.Context) (string, string, bool) {
+		return key, value, true
+	}
+}
+
+type commentValuesKey struct{}
+
+// ContextWithComment returns context carrying value of tag read by ContextTag,
+// for example route or traceparent set by HTTP middleware.
+func ContextWithComment(ctx context.Context, key, value string) context.Context {
+	existing, _ := ctx.Value(commentValuesKey{}).(map[string]string)
+	values := make(map[string]string, len(existing)+1)
+	for k, v := range existing {
+		values[k] = v
+	}
+	values[key] = value
+	return context.WithValue(ctx, commentValuesKey{}, values)
+}
+
+// ContextTag tags statement with value set by ContextWithComment.
+func ContextTag(key string) CommentTag {
+	return func(ctx context.Context) (string, string, bool) {
+		values, _ := ctx.Value(commentValuesKey{}).(map[string]string)
+		v, ok := values[key]
+		return key, v, ok
+	}
+}
+
+// DefaultCommentTags returns tags of transaction name, route from context and
+// service name as application. Values changing per transaction or request,
+// such as TxIDTag and traceparent, are not included as they defeat prepared
+// statement cache, see TxIDTag.
+func DefaultCommentTags(service string) []CommentTag {
+	return []CommentTag{
+		TxNameTag(),
+		ContextTag("route"),
+		StaticTag("application", service),
+	}
+}
+
+// SQLCommentOptions configures SQLCommenter.
+type SQLCommentOptions struct {
+	Tags []CommentTag
+
+	// Prepend places comment before statement, by default it is appended
+	// as sqlcommenter specification requires.
+	Prepend bool
+}
+
+// WithSQLComment comments every statement executed via Pool with tags.
+func WithSQLComment(opts SQLCommentOptions) PoolOption {
+	return WithQueryInterceptors(SQLCommenter(opts))
+}
+
+// SQLCommenter returns QueryInterceptor adding sqlcommenter comment
+// /*key='value',...*/ to statement. Statements already containing comment
+// are left untouched.
+func SQLCommenter(opts SQLCommentOptions) QueryInterceptor {
+	return func(ctx context.Context, st Statement, next func(ctx context.Context, st Statement) StatementResult) StatementResult {
+		st.SQL = commentSQL(ctx, st.SQL, opts)
+		return next(ctx, st)
+	}
+}
+
+func commentSQL(ctx context.Context, sql string, opts SQLCommentOptions) string {
+	if strings.Contains(sql, "/*") || strings.Contains(sql, "--") {
+		return sql
+	}
+
+	pairs := make([]string, 0, len(opts.Tags))
+	for _, tag := range opts.Tags {
+		k, v, ok := tag(ctx)
+		if !ok || k == "" {
+			continue
+		}
+		pairs = append(pairs, commentEscape(k)+"='"+commentEscape(v)+"'")
+	}
+	if len(pairs) == 0 {
+		return sql
+	}
+	slices.Sort(pairs)
+
+	comment := "/*" + strings.Join(pairs, ",") + "*/"
+	if opts.Prepend {
+		return comment + " " + sql
+	}
+
+	trimmed := strings.TrimRight(sql, " \t\n;")
+	return trimmed + " " + comment + sql[len(trimmed):]
+}
+
+// commentEscape url-encodes s per sqlcommenter spec, quotes, "$" and "*/"
+// are encoded too so comment cannot be closed early or clash with placeholders.
+func commentEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
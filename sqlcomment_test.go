@@ -0,0 +1,105 @@
+package pgxatomic
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCommentSQL(t *testing.T) {
+	txCtx := withTxState(context.Background(), &txState{info: TxInfo{Name: "create order", ID: "abc"}})
+	txCtx = ContextWithComment(txCtx, "route", "/orders/{id}")
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		sql  string
+		opts SQLCommentOptions
+		want string
+	}{
+		{
+			name: "no tags",
+			ctx:  txCtx,
+			sql:  "SELECT 1",
+			want: "SELECT 1",
+		},
+		{
+			name: "tags omitted outside tx",
+			ctx:  context.Background(),
+			sql:  "SELECT 1",
+			opts: SQLCommentOptions{Tags: []CommentTag{TxNameTag(), TxIDTag(), ContextTag("route")}},
+			want: "SELECT 1",
+		},
+		{
+			name: "default tags sorted and encoded",
+			ctx:  txCtx,
+			sql:  "SELECT 1",
+			opts: SQLCommentOptions{Tags: DefaultCommentTags("billing api")},
+			want: "SELECT 1 /*application='billing%20api',route='%2Forders%2F%7Bid%7D',tx_name='create%20order'*/",
+		},
+		{
+			name: "before trailing semicolon",
+			ctx:  txCtx,
+			sql:  "SELECT 1;\n",
+			opts: SQLCommentOptions{Tags: []CommentTag{TxIDTag()}},
+			want: "SELECT 1 /*tx_id='abc'*/;\n",
+		},
+		{
+			name: "prepend",
+			ctx:  txCtx,
+			sql:  "SELECT 1",
+			opts: SQLCommentOptions{Tags: []CommentTag{TxIDTag()}, Prepend: true},
+			want: "/*tx_id='abc'*/ SELECT 1",
+		},
+		{
+			name: "existing comment untouched",
+			ctx:  txCtx,
+			sql:  "SELECT 1 /* hint */",
+			opts: SQLCommentOptions{Tags: []CommentTag{TxIDTag()}},
+			want: "SELECT 1 /* hint */",
+		},
+		{
+			name: "value cannot close comment",
+			ctx:  txCtx,
+			sql:  "SELECT 1",
+			opts: SQLCommentOptions{Tags: []CommentTag{StaticTag("k", "x'*/ DROP TABLE users; --")}},
+			want: "SELECT 1 /*k='x%27%2A%2F%20DROP%20TABLE%20users%3B%20--'*/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, commentSQL(tt.ctx, tt.sql, tt.opts))
+		})
+	}
+}
+
+func TestSQLCommenter_Placeholders(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockTx := NewMockTx(ctrl)
+	placeholder := regexp.MustCompile(`\$\d+`)
+
+	const sql = "UPDATE users SET name = $1 WHERE id = $2"
+
+	var got string
+	mockTx.EXPECT().Exec(gomock.Any(), gomock.Any(), "John", 1).
+		DoAndReturn(func(_ context.Context, sql string, _ ...any) (pgconn.CommandTag, error) {
+			got = sql
+			return pgconn.NewCommandTag("UPDATE 1"), nil
+		})
+
+	p := Pool{}
+	WithSQLComment(SQLCommentOptions{Tags: []CommentTag{StaticTag("route", "/users/$3?id=$1")}})(&p)
+
+	_, err := p.Exec(WithTx(context.Background(), mockTx), sql, "John", 1)
+	assert.NoError(t, err)
+
+	assert.Equal(t, sql+" /*route='%2Fusers%2F%243%3Fid%3D%241'*/", got)
+	assert.Equal(t, placeholder.FindAllString(sql, -1), placeholder.FindAllString(got, -1))
+}
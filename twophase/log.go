@@ -0,0 +1,84 @@
+package twophase
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/ysomad/pgxatomic"
+)
+
+// Migration creates decision log table used by TableLog.
+//
+//go:embed migration.sql
+var Migration string
+
+// DecisionLog durably records decisions to commit prepared transactions,
+// transaction without recorded decision is rolled back by recovery.
+type DecisionLog interface {
+	// LogCommit records decision to commit gid prepared on participants, it
+	// must be durable when LogCommit returns.
+	LogCommit(ctx context.Context, gid string, participants []string) error
+	// Committed reports whether decision to commit gid is recorded.
+	Committed(ctx context.Context, gid string) (bool, error)
+	// Forget removes decision after gid is committed on all participants.
+	Forget(ctx context.Context, gid string) error
+}
+
+// TableLog is DecisionLog stored in Postgres table created by Migration,
+// it should be kept in database which is not a participant.
+type TableLog struct {
+	runner pgxatomic.TxRunner
+}
+
+var _ DecisionLog = (*TableLog)(nil)
+
+func NewTableLog(runner pgxatomic.TxRunner) *TableLog {
+	return &TableLog{runner: runner}
+}
+
+func (l *TableLog) LogCommit(ctx context.Context, gid string, participants []string) error {
+	return l.runner.Run(ctx, func(ctx context.Context) error {
+		_, err := pgxatomic.Exec(ctx, pgxatomic.TxFromContext(ctx),
+			"INSERT INTO pgxatomic_2pc_decisions (gid, participants) VALUES ($1, $2)", gid, participants)
+		return err
+	})
+}
+
+func (l *TableLog) Committed(ctx context.Context, gid string) (bool, error) {
+	var committed bool
+	err := l.runner.Run(ctx, func(ctx context.Context) error {
+		err := pgxatomic.QueryRow(ctx, pgxatomic.TxFromContext(ctx),
+			"SELECT true FROM pgxatomic_2pc_decisions WHERE gid = $1", gid).Scan(&committed)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return err
+	})
+	return committed, err
+}
+
+func (l *TableLog) Forget(ctx context.Context, gid string) error {
+	return l.runner.Run(ctx, func(ctx context.Context) error {
+		_, err := pgxatomic.Exec(ctx, pgxatomic.TxFromContext(ctx),
+			"DELETE FROM pgxatomic_2pc_decisions WHERE gid = $1", gid)
+		return err
+	})
+}
+
+// Purge deletes decisions recorded before olderThan which were not forgotten
+// because Forget failed, and returns number of deleted decisions. It must not
+// remove decisions of transactions which may still need recovery.
+func (l *TableLog) Purge(ctx context.Context, olderThan time.Time) (int64, error) {
+	var n int64
+	err := l.runner.Run(ctx, func(ctx context.Context) error {
+		tag, err := pgxatomic.Exec(ctx, pgxatomic.TxFromContext(ctx),
+			"DELETE FROM pgxatomic_2pc_decisions WHERE created_at < $1", olderThan)
+		n = tag.RowsAffected()
+		return err
+	})
+	return n, err
+}
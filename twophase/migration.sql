@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS pgxatomic_2pc_decisions (
+    gid          text        PRIMARY KEY,
+    participants text[]      NOT NULL,
+    created_at   timestamptz NOT NULL DEFAULT now()
+);
@@ -0,0 +1,377 @@
+// Package twophase implements two-phase commit across several databases on
+// top of pgxatomic.
+//
+// Coordinator runs callback in transaction on each participant, prepares all
+// of them with PREPARE TRANSACTION, records decision in DecisionLog and
+// commits them with COMMIT PREPARED. Transactions prepared before crash are
+// resolved by Recover. Participants must have max_prepared_transactions
+// greater than zero and Migration must be applied to database of TableLog.
+//
+// Branches are begun with BeginTx of participants, not with pgxatomic.Runner:
+// Runner commits or rolls back when its callback returns and may retry it,
+// while branch must stay open until every participant is prepared and cannot
+// be retried alone. So Runner options such as transaction interceptors,
+// metrics and logging do not apply to branches, query interceptors from
+// context do.
+package twophase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/ysomad/pgxatomic"
+)
+
+// Participant is database taking part in distributed transaction,
+// *pgxpool.Pool and *pgx.Conn implement it.
+type Participant interface {
+	pgxatomic.TxStarter
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// resolveTimeout limits rollback and commit of prepared transactions which
+// are run with context that is not canceled with caller's one, so
+// transactions do not hold locks until Recover after caller gives up.
+const resolveTimeout = 30 * time.Second
+
+// ErrOutcomeUnknown is returned if decision may or may not be logged, for
+// example because connection dropped while committing it. Prepared
+// transactions are left to Recover, which resolves them by the log.
+var ErrOutcomeUnknown = errors.New("twophase: outcome unknown")
+
+// ErrCommitIncomplete is returned if commit was decided but some participants
+// could not commit prepared transaction, they are committed by Recover.
+var ErrCommitIncomplete = errors.New("twophase: commit incomplete")
+
+// Config configures Coordinator.
+type Config struct {
+	// TxOptions are used to begin transaction on each participant.
+	TxOptions pgx.TxOptions
+
+	// Prefix of global transaction identifiers, "pgxatomic" by default.
+	// Coordinators sharing participants must use different prefixes.
+	Prefix string
+}
+
+// Coordinator runs distributed transactions on keyed participants.
+type Coordinator struct {
+	participants map[string]Participant
+	keys         []string
+	log          DecisionLog
+	cfg          Config
+}
+
+func NewCoordinator(participants map[string]Participant, log DecisionLog, cfg Config) (*Coordinator, error) {
+	if len(participants) == 0 {
+		return nil, errors.New("twophase: participants cannot be empty")
+	}
+	if log == nil {
+		return nil, errors.New("twophase: decision log cannot be nil")
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = "pgxatomic"
+	}
+	if strings.Contains(cfg.Prefix, ":") {
+		return nil, errors.New("twophase: prefix cannot contain colon")
+	}
+	return &Coordinator{
+		participants: participants,
+		keys:         slices.Sorted(maps.Keys(participants)),
+		log:          log,
+		cfg:          cfg,
+	}, nil
+}
+
+// Branches gives access to transactions of participants in Run.
+type Branches struct {
+	ctx context.Context
+	txs map[string]pgx.Tx
+}
+
+// Context returns context carrying transaction of participant with key, so
+// pgxatomic.Exec, Query and QueryRow use it. It panics if key is unknown.
+func (b *Branches) Context(key string) context.Context {
+	tx, ok := b.txs[key]
+	if !ok {
+		panic("twophase: unknown participant " + key)
+	}
+	return pgxatomic.WithTx(b.ctx, tx)
+}
+
+// Run begins transaction on each participant and runs fn. All transactions
+// are rolled back if fn fails, otherwise they are prepared and committed.
+// Transactions are rolled back if any of them fails to prepare or decision
+// is known not to be logged, and committed if decision turns out to be logged
+// although logging failed. ErrOutcomeUnknown is returned if neither is known.
+// ErrCommitIncomplete is returned if decision is logged but some participants
+// did not commit.
+func (c *Coordinator) Run(ctx context.Context, fn func(ctx context.Context, b *Branches) error) error {
+	gid, err := newGID()
+	if err != nil {
+		return err
+	}
+
+	b := &Branches{ctx: ctx, txs: make(map[string]pgx.Tx, len(c.keys))}
+
+	rollback := func(cause error) error {
+		ctx, cancel := resolveContext(ctx)
+		defer cancel()
+
+		errs := []error{cause}
+		for _, key := range c.keys {
+			if tx, ok := b.txs[key]; ok {
+				if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+					errs = append(errs, fmt.Errorf("twophase: rollback %s: %w", key, err))
+				}
+			}
+		}
+		return errors.Join(errs...)
+	}
+
+	for _, key := range c.keys {
+		tx, err := c.participants[key].BeginTx(ctx, c.cfg.TxOptions)
+		if err != nil {
+			return rollback(fmt.Errorf("twophase: begin %s: %w", key, err))
+		}
+		b.txs[key] = tx
+	}
+
+	if err := c.callFn(ctx, b, fn, rollback); err != nil {
+		return err
+	}
+
+	var prepared []string
+	for _, key := range c.keys {
+		if err := c.prepare(ctx, b.txs[key], c.gid(gid, key)); err != nil {
+			err = fmt.Errorf("twophase: prepare %s: %w", key, err)
+			return errors.Join(rollback(err), c.rollbackPrepared(ctx, gid, prepared))
+		}
+		prepared = append(prepared, key)
+	}
+
+	// from now on transactions survive crash and are resolved by Recover
+	// if anything below fails
+	if err := c.log.LogCommit(ctx, gid, c.keys); err != nil {
+		err = fmt.Errorf("twophase: log commit: %w", err)
+		switch c.decision(ctx, gid, err) {
+		case decisionAbsent:
+			return errors.Join(err, c.rollbackPrepared(ctx, gid, prepared))
+		case decisionUnknown:
+			// rolling back could contradict durable decision found by Recover
+			return errors.Join(ErrOutcomeUnknown, err)
+		}
+	}
+
+	// decision is made, so commit even if caller cancels
+	ctx, cancel := resolveContext(ctx)
+	defer cancel()
+
+	var errs []error
+	for _, key := range c.keys {
+		if _, err := c.participants[key].Exec(ctx, "COMMIT PREPARED "+quote(c.gid(gid, key))); err != nil {
+			errs = append(errs, fmt.Errorf("twophase: commit prepared %s: %w", key, err))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(append([]error{ErrCommitIncomplete}, errs...)...)
+	}
+
+	// decision which is not forgotten is only kept until purged
+	_ = c.log.Forget(ctx, gid)
+	return nil
+}
+
+type decisionState int
+
+const (
+	decisionAbsent decisionState = iota
+	decisionLogged
+	decisionUnknown
+)
+
+// decision returns whether commit decision of gid is logged after LogCommit
+// failed with err. Decision is absent if its commit was aborted, otherwise
+// log is checked again.
+func (c *Coordinator) decision(ctx context.Context, gid string, err error) decisionState {
+	var commitErr *pgxatomic.CommitError
+	if errors.As(err, &commitErr) && commitErr.Outcome == pgxatomic.CommitAborted {
+		return decisionAbsent
+	}
+
+	ctx, cancel := resolveContext(ctx)
+	defer cancel()
+
+	committed, err := c.log.Committed(ctx, gid)
+	switch {
+	case err != nil:
+		return decisionUnknown
+	case committed:
+		return decisionLogged
+	default:
+		return decisionAbsent
+	}
+}
+
+func (c *Coordinator) callFn(ctx context.Context, b *Branches, fn func(ctx context.Context, b *Branches) error, rollback func(error) error) error {
+	defer func() {
+		if p := recover(); p != nil {
+			_ = rollback(fmt.Errorf("twophase: panic: %v", p))
+			panic(p)
+		}
+	}()
+	if err := fn(ctx, b); err != nil {
+		return rollback(err)
+	}
+	return nil
+}
+
+// prepare prepares tx and releases it, tx is left open if it fails.
+func (c *Coordinator) prepare(ctx context.Context, tx pgx.Tx, gid string) error {
+	tag, err := tx.Exec(ctx, "PREPARE TRANSACTION "+quote(gid))
+	if err != nil {
+		return err
+	}
+	// aborted transaction is rolled back instead of being prepared
+	if tag.String() != "PREPARE TRANSACTION" {
+		return pgx.ErrTxCommitRollback
+	}
+	// session has no transaction after PREPARE, so commit only releases tx,
+	// it must not fail because of canceled ctx as PREPARE succeeded
+	ctx, cancel := resolveContext(ctx)
+	defer cancel()
+	return tx.Commit(ctx)
+}
+
+func (c *Coordinator) rollbackPrepared(ctx context.Context, gid string, keys []string) error {
+	ctx, cancel := resolveContext(ctx)
+	defer cancel()
+
+	var errs []error
+	for _, key := range keys {
+		if _, err := c.participants[key].Exec(ctx, "ROLLBACK PREPARED "+quote(c.gid(gid, key))); err != nil {
+			errs = append(errs, fmt.Errorf("twophase: rollback prepared %s: %w", key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// gid returns identifier of transaction prepared on participant with key,
+// it is unique even if participants share database.
+func (c *Coordinator) gid(gid, key string) string {
+	return c.cfg.Prefix + ":" + gid + ":" + key
+}
+
+// parseGID returns global transaction identifier from identifier of
+// prepared transaction.
+func (c *Coordinator) parseGID(prepared string) (string, bool) {
+	rest, ok := strings.CutPrefix(prepared, c.cfg.Prefix+":")
+	if !ok {
+		return "", false
+	}
+	gid, _, ok := strings.Cut(rest, ":")
+	return gid, ok
+}
+
+// Recover resolves transactions prepared by coordinator with the same prefix
+// more than olderThan ago: transactions with logged decision are committed
+// and the rest are rolled back. olderThan must exceed duration of Run, so
+// transactions being prepared are not rolled back. It returns number of
+// resolved transactions.
+func (c *Coordinator) Recover(ctx context.Context, olderThan time.Duration) (int, error) {
+	var (
+		resolved int
+		errs     []error
+		// pending reports whether committed gid has unresolved transactions
+		pending = make(map[string]bool)
+	)
+
+	for _, key := range c.keys {
+		p := c.participants[key]
+
+		rows, err := p.Query(ctx,
+			"SELECT gid FROM pg_prepared_xacts WHERE database = current_database() AND gid LIKE $1 AND prepared < now() - $2::interval",
+			c.cfg.Prefix+":%", fmt.Sprintf("%d milliseconds", olderThan.Milliseconds()))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("twophase: recover %s: %w", key, err))
+			continue
+		}
+		prepared, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("twophase: recover %s: %w", key, err))
+			continue
+		}
+
+		for _, pgid := range prepared {
+			gid, ok := c.parseGID(pgid)
+			if !ok {
+				continue
+			}
+
+			committed, err := c.log.Committed(ctx, gid)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("twophase: recover %s: %w", pgid, err))
+				pending[gid] = true
+				continue
+			}
+
+			stmt := "ROLLBACK PREPARED "
+			if committed {
+				stmt = "COMMIT PREPARED "
+			}
+			if _, err := p.Exec(ctx, stmt+quote(pgid)); err != nil {
+				errs = append(errs, fmt.Errorf("twophase: recover %s: %w", pgid, err))
+				pending[gid] = true
+				continue
+			}
+			resolved++
+
+			if committed {
+				if _, ok := pending[gid]; !ok {
+					pending[gid] = false
+				}
+			}
+		}
+	}
+
+	// decision may be needed by participants which could not be listed
+	if len(errs) == 0 {
+		for gid, failed := range pending {
+			if !failed {
+				_ = c.log.Forget(ctx, gid)
+			}
+		}
+	}
+
+	return resolved, errors.Join(errs...)
+}
+
+// resolveContext returns context which is not canceled with ctx and expires
+// after resolveTimeout.
+func resolveContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), resolveTimeout)
+}
+
+func newGID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("twophase: generate gid: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// quote quotes s as SQL string literal, PREPARE TRANSACTION does not accept
+// parameters.
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
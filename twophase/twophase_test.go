@@ -0,0 +1,453 @@
+package twophase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ysomad/pgxatomic"
+	"github.com/ysomad/pgxatomic/internal/pgtest"
+	"github.com/ysomad/pgxatomic/pgxatomictest"
+)
+
+var errTest = errors.New("test error")
+
+// memLog is in-memory DecisionLog.
+type memLog struct {
+	mu           sync.Mutex
+	err          error
+	committedErr error
+	onLog        func()
+	decisions    map[string][]string
+	forgotten    []string
+
+	// logOnErr records decision although LogCommit returns err, as if
+	// connection dropped after decision was committed
+	logOnErr bool
+}
+
+func newMemLog() *memLog {
+	return &memLog{decisions: make(map[string][]string)}
+}
+
+func (l *memLog) LogCommit(_ context.Context, gid string, participants []string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.onLog != nil {
+		l.onLog()
+	}
+	if l.err != nil {
+		if l.logOnErr {
+			l.decisions[gid] = participants
+		}
+		return l.err
+	}
+	l.decisions[gid] = participants
+	return nil
+}
+
+func (l *memLog) Committed(_ context.Context, gid string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.committedErr != nil {
+		return false, l.committedErr
+	}
+	_, ok := l.decisions[gid]
+	return ok, nil
+}
+
+func (l *memLog) Forget(_ context.Context, gid string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.decisions, gid)
+	l.forgotten = append(l.forgotten, gid)
+	return nil
+}
+
+func participant(t *testing.T) (*pgxatomictest.Server, *pgxpool.Pool) {
+	t.Helper()
+
+	srv := pgxatomictest.NewServer(t)
+	pool, err := pgxpool.New(context.Background(), srv.ConnString())
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	return srv, pool
+}
+
+// failOn makes srv reply with error to queries with prefix.
+func failOn(srv *pgxatomictest.Server, prefix string) {
+	srv.Respond(func(sql string) (pgxatomictest.Response, bool) {
+		if strings.HasPrefix(sql, prefix) {
+			return pgxatomictest.Response{Err: &pgconn.PgError{Code: "XX000", Message: "injected"}}, true
+		}
+		return pgxatomictest.Response{}, false
+	})
+}
+
+type fixture struct {
+	srvA, srvB *pgxatomictest.Server
+	log        *memLog
+	c          *Coordinator
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+
+	srvA, poolA := participant(t)
+	srvB, poolB := participant(t)
+	log := newMemLog()
+
+	c, err := NewCoordinator(map[string]Participant{"a": poolA, "b": poolB}, log, Config{})
+	require.NoError(t, err)
+
+	return &fixture{srvA: srvA, srvB: srvB, log: log, c: c}
+}
+
+func (f *fixture) run(fnErr error) (string, error) {
+	return f.runContext(context.Background(), func() error { return fnErr })
+}
+
+func (f *fixture) runContext(ctx context.Context, fnErr func() error) (string, error) {
+	var gid string
+	err := f.c.Run(ctx, func(ctx context.Context, b *Branches) error {
+		for _, key := range []string{"a", "b"} {
+			if _, err := pgxatomic.Exec(b.Context(key), nil, "INSERT INTO t VALUES (1)"); err != nil {
+				return err
+			}
+		}
+		return fnErr()
+	})
+
+	// gid is random, take it from the first prepared transaction
+	for _, q := range f.srvA.Queries() {
+		if s, ok := strings.CutPrefix(q, "PREPARE TRANSACTION 'pgxatomic:"); ok {
+			gid, _, _ = strings.Cut(s, ":")
+		}
+	}
+	return gid, err
+}
+
+func TestNewCoordinator(t *testing.T) {
+	_, err := NewCoordinator(nil, newMemLog(), Config{})
+	assert.Error(t, err)
+
+	_, err = NewCoordinator(map[string]Participant{"a": nil}, nil, Config{})
+	assert.Error(t, err)
+
+	_, err = NewCoordinator(map[string]Participant{"a": nil}, newMemLog(), Config{Prefix: "a:b"})
+	assert.Error(t, err)
+}
+
+func TestCoordinator_Run(t *testing.T) {
+	f := newFixture(t)
+
+	gid, err := f.run(nil)
+	require.NoError(t, err)
+	require.NotEmpty(t, gid)
+
+	for key, srv := range map[string]*pgxatomictest.Server{"a": f.srvA, "b": f.srvB} {
+		assert.Equal(t, []string{
+			"begin",
+			"INSERT INTO t VALUES (1)",
+			"PREPARE TRANSACTION 'pgxatomic:" + gid + ":" + key + "'",
+			"commit",
+			"COMMIT PREPARED 'pgxatomic:" + gid + ":" + key + "'",
+		}, srv.Queries(), key)
+	}
+	assert.Equal(t, []string{gid}, f.log.forgotten)
+	assert.Empty(t, f.log.decisions)
+}
+
+func TestCoordinator_RunError(t *testing.T) {
+	f := newFixture(t)
+
+	_, err := f.run(errTest)
+	assert.ErrorIs(t, err, errTest)
+
+	want := []string{"begin", "INSERT INTO t VALUES (1)", "rollback"}
+	assert.Equal(t, want, f.srvA.Queries())
+	assert.Equal(t, want, f.srvB.Queries())
+	assert.Empty(t, f.log.decisions)
+}
+
+func TestCoordinator_PrepareFailure(t *testing.T) {
+	f := newFixture(t)
+	failOn(f.srvB, "PREPARE TRANSACTION")
+
+	gid, err := f.run(nil)
+	assert.ErrorContains(t, err, "twophase: prepare b")
+
+	assert.Equal(t, []string{
+		"begin",
+		"INSERT INTO t VALUES (1)",
+		"PREPARE TRANSACTION 'pgxatomic:" + gid + ":a'",
+		"commit",
+		"ROLLBACK PREPARED 'pgxatomic:" + gid + ":a'",
+	}, f.srvA.Queries())
+	assert.Equal(t, []string{
+		"begin",
+		"INSERT INTO t VALUES (1)",
+		"PREPARE TRANSACTION 'pgxatomic:" + gid + ":b'",
+		"rollback",
+	}, f.srvB.Queries())
+	assert.Empty(t, f.log.decisions)
+}
+
+func TestCoordinator_LogFailure(t *testing.T) {
+	f := newFixture(t)
+	f.log.err = errTest
+
+	gid, err := f.run(nil)
+	assert.ErrorIs(t, err, errTest)
+
+	for key, srv := range map[string]*pgxatomictest.Server{"a": f.srvA, "b": f.srvB} {
+		queries := srv.Queries()
+		assert.Equal(t, "ROLLBACK PREPARED 'pgxatomic:"+gid+":"+key+"'", queries[len(queries)-1], key)
+	}
+}
+
+func TestCoordinator_LogOutcome(t *testing.T) {
+	unknown := &pgxatomic.CommitError{Outcome: pgxatomic.CommitUnknown, Err: errTest}
+	aborted := &pgxatomic.CommitError{Outcome: pgxatomic.CommitAborted, Err: errTest}
+
+	tests := []struct {
+		name         string
+		logErr       error
+		logOnErr     bool
+		committedErr error
+		wantErr      error
+		want         string
+	}{
+		{
+			name:     "unknown but logged",
+			logErr:   unknown,
+			logOnErr: true,
+			want:     "COMMIT PREPARED 'pgxatomic:%s:%s'",
+		},
+		{
+			name:    "unknown and not logged",
+			logErr:  unknown,
+			wantErr: unknown,
+			want:    "ROLLBACK PREPARED 'pgxatomic:%s:%s'",
+		},
+		{
+			name:         "unknown and check failed",
+			logErr:       unknown,
+			logOnErr:     true,
+			committedErr: errors.New("check failed"),
+			wantErr:      ErrOutcomeUnknown,
+			want:         "PREPARE TRANSACTION 'pgxatomic:%s:%s'",
+		},
+		{
+			name:         "aborted",
+			logErr:       aborted,
+			committedErr: errors.New("not checked"),
+			wantErr:      aborted,
+			want:         "ROLLBACK PREPARED 'pgxatomic:%s:%s'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			f.log.err = tt.logErr
+			f.log.logOnErr = tt.logOnErr
+			f.log.committedErr = tt.committedErr
+
+			gid, err := f.run(nil)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			for key, srv := range map[string]*pgxatomictest.Server{"a": f.srvA, "b": f.srvB} {
+				// prepared transaction is released with commit after PREPARE
+				queries := srv.Queries()
+				last := queries[len(queries)-1]
+				if last == "commit" {
+					last = queries[len(queries)-2]
+				}
+				assert.Equal(t, fmt.Sprintf(tt.want, gid, key), last, key)
+			}
+		})
+	}
+}
+
+func TestCoordinator_CommitIncomplete(t *testing.T) {
+	f := newFixture(t)
+	failOn(f.srvB, "COMMIT PREPARED")
+
+	gid, err := f.run(nil)
+	assert.ErrorIs(t, err, ErrCommitIncomplete)
+	assert.ErrorContains(t, err, "commit prepared b")
+
+	// decision is kept for recovery
+	assert.Contains(t, f.log.decisions, gid)
+	assert.Empty(t, f.log.forgotten)
+}
+
+func TestCoordinator_Canceled(t *testing.T) {
+	tests := []struct {
+		name    string
+		logErr  error
+		fnErr   error
+		wantErr error
+		want    string
+	}{
+		{name: "after decision", want: "COMMIT PREPARED 'pgxatomic:%s:%s'"},
+		{name: "log failure", logErr: errTest, wantErr: errTest, want: "ROLLBACK PREPARED 'pgxatomic:%s:%s'"},
+		{name: "fn failure", fnErr: errTest, wantErr: errTest, want: "rollback"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			// caller gives up after transactions are prepared or fn fails
+			f.log.err = tt.logErr
+			f.log.onLog = cancel
+
+			gid, err := f.runContext(ctx, func() error {
+				if tt.fnErr != nil {
+					cancel()
+				}
+				return tt.fnErr
+			})
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			for key, srv := range map[string]*pgxatomictest.Server{"a": f.srvA, "b": f.srvB} {
+				want := tt.want
+				if strings.Contains(want, "%s") {
+					want = fmt.Sprintf(want, gid, key)
+				}
+				queries := srv.Queries()
+				assert.Equal(t, want, queries[len(queries)-1], key)
+			}
+		})
+	}
+}
+
+func TestCoordinator_Recover(t *testing.T) {
+	f := newFixture(t)
+	f.log.decisions["c0ffee"] = []string{"a", "b"}
+
+	f.srvA.Respond(func(sql string) (pgxatomictest.Response, bool) {
+		if strings.HasPrefix(sql, "SELECT gid FROM pg_prepared_xacts") {
+			return pgxatomictest.Response{Columns: []string{"gid"}, Rows: [][]string{
+				{"pgxatomic:c0ffee:a"},
+				{"pgxatomic:dead:a"},
+			}}, true
+		}
+		return pgxatomictest.Response{}, false
+	})
+
+	n, err := f.c.Recover(context.Background(), time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	queries := f.srvA.Queries()
+	require.Len(t, queries, 3)
+	assert.Contains(t, queries[0], "pg_prepared_xacts")
+	assert.Equal(t, []string{
+		"COMMIT PREPARED 'pgxatomic:c0ffee:a'",
+		"ROLLBACK PREPARED 'pgxatomic:dead:a'",
+	}, queries[1:])
+	assert.Equal(t, []string{"c0ffee"}, f.log.forgotten)
+}
+
+func TestCoordinator_RecoverListFailure(t *testing.T) {
+	f := newFixture(t)
+	f.log.decisions["c0ffee"] = []string{"a", "b"}
+
+	f.srvA.Respond(func(sql string) (pgxatomictest.Response, bool) {
+		if strings.HasPrefix(sql, "SELECT gid FROM pg_prepared_xacts") {
+			return pgxatomictest.Response{Columns: []string{"gid"}, Rows: [][]string{{"pgxatomic:c0ffee:a"}}}, true
+		}
+		return pgxatomictest.Response{}, false
+	})
+	failOn(f.srvB, "SELECT gid FROM pg_prepared_xacts")
+
+	n, err := f.c.Recover(context.Background(), time.Minute)
+	assert.ErrorContains(t, err, "twophase: recover b")
+	assert.Equal(t, 1, n)
+
+	// b may still have transaction waiting for decision
+	assert.Contains(t, f.log.decisions, "c0ffee")
+}
+
+func TestQuote(t *testing.T) {
+	assert.Equal(t, "'it''s'", quote("it's"))
+}
+
+func TestCoordinator_Postgres(t *testing.T) {
+	pool := pgtest.Pool(t)
+
+	ctx := context.Background()
+
+	var maxPrepared int
+	require.NoError(t, pool.QueryRow(ctx, "SELECT current_setting('max_prepared_transactions')::int").Scan(&maxPrepared))
+	if maxPrepared == 0 {
+		t.Skip("max_prepared_transactions is zero")
+	}
+
+	_, err := pool.Exec(ctx, Migration+"; DROP TABLE IF EXISTS pgxatomic_2pc_test; CREATE TABLE pgxatomic_2pc_test (key text)")
+	require.NoError(t, err)
+	t.Cleanup(func() { _, _ = pool.Exec(context.Background(), "DROP TABLE IF EXISTS pgxatomic_2pc_test") })
+
+	runner, err := pgxatomic.NewRunner(pool, pgx.TxOptions{})
+	require.NoError(t, err)
+
+	// both participants share database, their transactions are prepared separately
+	c, err := NewCoordinator(map[string]Participant{"a": pool, "b": pool}, NewTableLog(runner), Config{Prefix: "pgxatomic_test"})
+	require.NoError(t, err)
+
+	err = c.Run(ctx, func(ctx context.Context, b *Branches) error {
+		for _, key := range []string{"a", "b"} {
+			if _, err := pgxatomic.Exec(b.Context(key), pool, "INSERT INTO pgxatomic_2pc_test VALUES ($1)", key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	var n int
+	require.NoError(t, pool.QueryRow(ctx, "SELECT count(*) FROM pgxatomic_2pc_test").Scan(&n))
+	assert.Equal(t, 2, n)
+
+	// transaction prepared without decision is rolled back by recovery
+	tx, err := pool.Begin(ctx)
+	require.NoError(t, err)
+	_, err = tx.Exec(ctx, "INSERT INTO pgxatomic_2pc_test VALUES ('orphan')")
+	require.NoError(t, err)
+	_, err = tx.Exec(ctx, "PREPARE TRANSACTION 'pgxatomic_test:0000:a'")
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit(ctx))
+
+	resolved, err := c.Recover(ctx, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, resolved)
+
+	require.NoError(t, pool.QueryRow(ctx, "SELECT count(*) FROM pg_prepared_xacts WHERE gid LIKE 'pgxatomic_test:%'").Scan(&n))
+	assert.Zero(t, n)
+	require.NoError(t, pool.QueryRow(ctx, "SELECT count(*) FROM pgxatomic_2pc_test").Scan(&n))
+	assert.Equal(t, 2, n)
+}
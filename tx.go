@@ -0,0 +1,97 @@
+package pgxatomic
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TxStarter starts transaction with options, it is used by Runner.
+type TxStarter interface {
+	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
+}
+
+// TxBeginner starts transaction, pgx.Tx implements it by starting savepoint.
+type TxBeginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+var (
+	_ TxStarter  = (*pgxpool.Pool)(nil)
+	_ TxStarter  = (*pgx.Conn)(nil)
+	_ TxBeginner = (*pgxpool.Pool)(nil)
+	_ TxBeginner = (*pgx.Conn)(nil)
+	_ TxBeginner = (pgx.Tx)(nil)
+)
+
+// TxRunner runs txFunc in transaction, it allows services to depend on
+// interface and replace Runner with NoopRunner or test double.
+type TxRunner interface {
+	Run(ctx context.Context, txFunc func(ctx context.Context) error, opts ...RunOption) error
+}
+
+var (
+	_ TxRunner = Runner{}
+	_ TxRunner = NoopRunner{}
+)
+
+// NoopRunner calls txFunc without transaction.
+type NoopRunner struct{}
+
+func (NoopRunner) Run(ctx context.Context, txFunc func(ctx context.Context) error, _ ...RunOption) error {
+	return txFunc(ctx)
+}
+
+// Propagation defines how Runner.Run behaves if context already carries transaction.
+type Propagation int
+
+const (
+	// PropagationNew starts new independent transaction, it is default.
+	PropagationNew Propagation = iota
+
+	// PropagationNested starts savepoint in transaction from context, so
+	// nested Run rolls back only its own changes. Nested runs are not retried
+	// since serialization failures abort the whole transaction.
+	PropagationNested
+
+	// PropagationJoin runs txFunc in transaction from context without
+	// savepoint, its error rolls back the whole transaction.
+	PropagationJoin
+)
+
+func (p Propagation) String() string {
+	switch p {
+	case PropagationNew:
+		return "new"
+	case PropagationNested:
+		return "nested"
+	case PropagationJoin:
+		return "join"
+	default:
+		return "unknown"
+	}
+}
+
+// WithPropagation sets behavior of Run called with transaction in context.
+func WithPropagation(p Propagation) RunnerOption {
+	return func(r *Runner) {
+		r.propagation = p
+	}
+}
+
+type propagationKey struct{}
+
+// ContextWithPropagation overrides propagation set by WithPropagation for
+// Run calls with ctx, it is used to turn nested transactions of code under
+// test into savepoints.
+func ContextWithPropagation(ctx context.Context, p Propagation) context.Context {
+	return context.WithValue(ctx, propagationKey{}, p)
+}
+
+func (r Runner) propagationFor(ctx context.Context) Propagation {
+	if p, ok := ctx.Value(propagationKey{}).(Propagation); ok {
+		return p
+	}
+	return r.propagation
+}
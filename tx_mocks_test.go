@@ -0,0 +1,139 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: tx.go
+//
+// Generated by this command:
+//
+//	mockgen -package pgxatomic -source tx.go -destination tx_mocks_test.go
+//
+
+// Package pgxatomic is a generated GoMock package.
+package pgxatomic
+
+import (
+	context "context"
+	reflect "reflect"
+
+	pgx "github.com/jackc/pgx/v5"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockTxStarter is a mock of TxStarter interface.
+type MockTxStarter struct {
+	ctrl     *gomock.Controller
+	recorder *MockTxStarterMockRecorder
+	isgomock struct{}
+}
+
+// MockTxStarterMockRecorder is the mock recorder for MockTxStarter.
+type MockTxStarterMockRecorder struct {
+	mock *MockTxStarter
+}
+
+// NewMockTxStarter creates a new mock instance.
+func NewMockTxStarter(ctrl *gomock.Controller) *MockTxStarter {
+	mock := &MockTxStarter{ctrl: ctrl}
+	mock.recorder = &MockTxStarterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTxStarter) EXPECT() *MockTxStarterMockRecorder {
+	return m.recorder
+}
+
+// BeginTx mocks base method.
+func (m *MockTxStarter) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BeginTx", ctx, txOptions)
+	ret0, _ := ret[0].(pgx.Tx)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BeginTx indicates an expected call of BeginTx.
+func (mr *MockTxStarterMockRecorder) BeginTx(ctx, txOptions any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BeginTx", reflect.TypeOf((*MockTxStarter)(nil).BeginTx), ctx, txOptions)
+}
+
+// MockTxBeginner is a mock of TxBeginner interface.
+type MockTxBeginner struct {
+	ctrl     *gomock.Controller
+	recorder *MockTxBeginnerMockRecorder
+	isgomock struct{}
+}
+
+// MockTxBeginnerMockRecorder is the mock recorder for MockTxBeginner.
+type MockTxBeginnerMockRecorder struct {
+	mock *MockTxBeginner
+}
+
+// NewMockTxBeginner creates a new mock instance.
+func NewMockTxBeginner(ctrl *gomock.Controller) *MockTxBeginner {
+	mock := &MockTxBeginner{ctrl: ctrl}
+	mock.recorder = &MockTxBeginnerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTxBeginner) EXPECT() *MockTxBeginnerMockRecorder {
+	return m.recorder
+}
+
+// Begin mocks base method.
+func (m *MockTxBeginner) Begin(ctx context.Context) (pgx.Tx, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Begin", ctx)
+	ret0, _ := ret[0].(pgx.Tx)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Begin indicates an expected call of Begin.
+func (mr *MockTxBeginnerMockRecorder) Begin(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Begin", reflect.TypeOf((*MockTxBeginner)(nil).Begin), ctx)
+}
+
+// MockTxRunner is a mock of TxRunner interface.
+type MockTxRunner struct {
+	ctrl     *gomock.Controller
+	recorder *MockTxRunnerMockRecorder
+	isgomock struct{}
+}
+
+// MockTxRunnerMockRecorder is the mock recorder for MockTxRunner.
+type MockTxRunnerMockRecorder struct {
+	mock *MockTxRunner
+}
+
+// NewMockTxRunner creates a new mock instance.
+func NewMockTxRunner(ctrl *gomock.Controller) *MockTxRunner {
+	mock := &MockTxRunner{ctrl: ctrl}
+	mock.recorder = &MockTxRunnerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTxRunner) EXPECT() *MockTxRunnerMockRecorder {
+	return m.recorder
+}
+
+// Run mocks base method.
+func (m *MockTxRunner) Run(ctx context.Context, txFunc func(context.Context) error, opts ...RunOption) error {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, txFunc}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Run", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Run indicates an expected call of Run.
+func (mr *MockTxRunnerMockRecorder) Run(ctx, txFunc any, opts ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, txFunc}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Run", reflect.TypeOf((*MockTxRunner)(nil).Run), varargs...)
+}
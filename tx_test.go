@@ -0,0 +1,119 @@
+package pgxatomic
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestNoopRunner(t *testing.T) {
+	var called bool
+	err := NoopRunner{}.Run(context.Background(), func(ctx context.Context) error {
+		called = true
+		assert.Nil(t, TxFromContext(ctx))
+		return errTest
+	})
+	assert.ErrorIs(t, err, errTest)
+	assert.True(t, called)
+}
+
+func TestRun_PropagationNested(t *testing.T) {
+	tests := []struct {
+		name    string
+		fnErr   error
+		wantErr error
+	}{
+		{name: "release", fnErr: nil, wantErr: nil},
+		{name: "rollback to savepoint", fnErr: errTest, wantErr: errTest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			parent := NewMockTx(ctrl)
+			sp := NewMockTx(ctrl)
+			parent.EXPECT().Begin(gomock.Any()).Return(sp, nil)
+			if tt.fnErr == nil {
+				sp.EXPECT().Commit(gomock.Any()).Return(nil)
+			} else {
+				sp.EXPECT().Rollback(gomock.Any()).Return(nil)
+			}
+
+			// db must not be used when ctx carries transaction
+			r, err := NewRunner(NewMockTxStarter(ctrl), pgx.TxOptions{}, WithPropagation(PropagationNested), WithRetry(3))
+			assert.NoError(t, err)
+
+			err = r.Run(WithTx(context.Background(), parent), func(ctx context.Context) error {
+				assert.Equal(t, sp, TxFromContext(ctx))
+				info, ok := TxInfoFromContext(ctx)
+				assert.True(t, ok)
+				assert.Equal(t, 1, info.Attempt)
+				return tt.fnErr
+			})
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestRun_PropagationJoin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	parent := NewMockTx(ctrl)
+
+	r, err := NewRunner(NewMockTxStarter(ctrl), pgx.TxOptions{}, WithPropagation(PropagationJoin))
+	assert.NoError(t, err)
+
+	err = r.Run(WithTx(context.Background(), parent), func(ctx context.Context) error {
+		assert.Equal(t, parent, TxFromContext(ctx))
+		return errTest
+	})
+	assert.ErrorIs(t, err, errTest)
+}
+
+func TestRun_PropagationNewIgnoresContextTx(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	parent := NewMockTx(ctrl)
+	tx := NewMockTx(ctrl)
+	db := NewMockTxStarter(ctrl)
+	db.EXPECT().BeginTx(gomock.Any(), gomock.Any()).Return(tx, nil)
+	tx.EXPECT().Commit(gomock.Any()).Return(nil)
+
+	r, err := NewRunner(db, pgx.TxOptions{})
+	assert.NoError(t, err)
+
+	err = r.Run(WithTx(context.Background(), parent), func(ctx context.Context) error {
+		assert.Equal(t, tx, TxFromContext(ctx))
+		info, _ := TxInfoFromContext(ctx)
+		assert.Equal(t, 0, info.Depth)
+		return nil
+	})
+	assert.NoError(t, err)
+}
+
+func TestRun_ContextWithPropagation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	parent := NewMockTx(ctrl)
+	sp := NewMockTx(ctrl)
+	parent.EXPECT().Begin(gomock.Any()).Return(sp, nil)
+	sp.EXPECT().Commit(gomock.Any()).Return(nil)
+
+	r, err := NewRunner(NewMockTxStarter(ctrl), pgx.TxOptions{})
+	assert.NoError(t, err)
+
+	ctx := ContextWithPropagation(WithTx(context.Background(), parent), PropagationNested)
+	err = r.Run(ctx, func(ctx context.Context) error {
+		assert.Equal(t, sp, TxFromContext(ctx))
+		return nil
+	})
+	assert.NoError(t, err)
+}
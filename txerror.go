@@ -0,0 +1,26 @@
+package pgxatomic
+
+// Operations of Runner reported in TxError.
+const (
+	OpSetup  = "setup"
+	OpTxFunc = "txfunc"
+	OpPanic  = "panic"
+)
+
+// TxError is returned by Runner.Run when transaction failed and following
+// rollback failed too, for example because connection is broken.
+// errors.Is and errors.As match both Cause and RollbackErr.
+type TxError struct {
+	// Op is operation failed with Cause.
+	Op          string
+	Cause       error
+	RollbackErr error
+}
+
+func (e *TxError) Error() string {
+	return "pgxatomic: " + e.Op + ": " + e.Cause.Error() + " (rollback: " + e.RollbackErr.Error() + ")"
+}
+
+func (e *TxError) Unwrap() []error {
+	return []error{e.Cause, e.RollbackErr}
+}
@@ -0,0 +1,113 @@
+package pgxatomic
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRun_RollbackError(t *testing.T) {
+	errConnBroken := errors.New("conn closed")
+
+	tests := []struct {
+		name        string
+		rollbackErr error
+		wantTxErr   bool
+	}{
+		{
+			name: "rollback ok",
+		},
+		{
+			name:        "tx already closed",
+			rollbackErr: pgx.ErrTxClosed,
+		},
+		{
+			name:        "rollback failed",
+			rollbackErr: errConnBroken,
+			wantTxErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockDB := NewMockTxStarter(ctrl)
+			mockTx := NewMockTx(ctrl)
+
+			mockDB.EXPECT().BeginTx(gomock.Any(), gomock.Any()).Return(mockTx, nil)
+			mockTx.EXPECT().Rollback(gomock.Any()).Return(tt.rollbackErr)
+
+			runner, err := NewRunner(mockDB, pgx.TxOptions{})
+			require.NoError(t, err)
+
+			err = runner.Run(context.Background(), func(ctx context.Context) error {
+				return errTest
+			})
+			assert.ErrorIs(t, err, errTest)
+
+			var txErr *TxError
+			if !tt.wantTxErr {
+				assert.Equal(t, errTest, err)
+				assert.False(t, errors.As(err, &txErr))
+				return
+			}
+
+			require.ErrorAs(t, err, &txErr)
+			assert.Equal(t, OpTxFunc, txErr.Op)
+			assert.Equal(t, errTest, txErr.Cause)
+			assert.Equal(t, tt.rollbackErr, txErr.RollbackErr)
+			assert.ErrorIs(t, err, tt.rollbackErr)
+			assert.Equal(t, "pgxatomic: txfunc: test error (rollback: conn closed)", err.Error())
+		})
+	}
+}
+
+func TestRun_CanceledRollback(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := NewMockTxStarter(ctrl)
+	mockTx := NewMockTx(ctrl)
+
+	mockDB.EXPECT().BeginTx(gomock.Any(), gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().Rollback(gomock.Any()).DoAndReturn(func(ctx context.Context) error {
+		return ctx.Err()
+	})
+
+	runner, err := NewRunner(mockDB, pgx.TxOptions{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err = runner.Run(ctx, func(ctx context.Context) error {
+		cancel()
+		return ctx.Err()
+	})
+	assert.Equal(t, context.Canceled, err, "rollback must not be canceled with ctx")
+}
+
+func TestRun_PanicRollbackError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := NewMockTxStarter(ctrl)
+	mockTx := NewMockTx(ctrl)
+
+	mockDB.EXPECT().BeginTx(gomock.Any(), gomock.Any()).Return(mockTx, nil)
+	mockTx.EXPECT().Rollback(gomock.Any()).Return(nil)
+
+	runner, err := NewRunner(mockDB, pgx.TxOptions{})
+	require.NoError(t, err)
+
+	assert.PanicsWithValue(t, "boom", func() {
+		_ = runner.Run(context.Background(), func(ctx context.Context) error {
+			panic("boom")
+		})
+	})
+}
@@ -0,0 +1,261 @@
+// Package uow implements unit of work on top of pgxatomic.
+//
+// UnitOfWork tracks new, dirty and deleted aggregates and flushes them in one
+// batch through transaction from context. Aggregates are inserted and updated
+// in order of their kinds and deleted in reverse order, so parents are written
+// before children and children are deleted before parents.
+package uow
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/ysomad/pgxatomic"
+)
+
+// Aggregate is entity tracked by UnitOfWork. Statements of UpdateSQL and
+// DeleteSQL must match row by ID and Version, so they affect no rows if
+// aggregate was changed concurrently, UpdateSQL is expected to increment
+// version.
+type Aggregate interface {
+	// Kind is type of aggregate, it defines flush order.
+	Kind() string
+	// ID identifies aggregate of its kind, it must be comparable.
+	ID() any
+	// Version is version aggregate was loaded with.
+	Version() int64
+
+	InsertSQL() (sql string, args []any)
+	UpdateSQL() (sql string, args []any)
+	DeleteSQL() (sql string, args []any)
+}
+
+// Op is change of aggregate.
+type Op int
+
+const (
+	OpInsert Op = iota
+	OpUpdate
+	OpDelete
+)
+
+func (op Op) String() string {
+	switch op {
+	case OpInsert:
+		return "insert"
+	case OpUpdate:
+		return "update"
+	case OpDelete:
+		return "delete"
+	default:
+		return "unknown"
+	}
+}
+
+// ConflictError is returned by Flush if update or delete affected no rows
+// because aggregate was changed or deleted concurrently, it unwraps to
+// pgxatomic.ErrStaleVersion so pgxatomic.RetryOnConflict retries it.
+type ConflictError struct {
+	Kind    string
+	ID      any
+	Version int64
+	Op      Op
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("uow: %s of %s %v version %d: version conflict", e.Op, e.Kind, e.ID, e.Version)
+}
+
+func (e *ConflictError) Unwrap() error {
+	return pgxatomic.ErrStaleVersion
+}
+
+type identity struct {
+	kind string
+	id   any
+}
+
+type entry struct {
+	agg Aggregate
+	op  Op
+}
+
+// UnitOfWork collects changes of aggregates, it is safe for concurrent use.
+type UnitOfWork struct {
+	order map[string]int
+
+	mu      sync.Mutex
+	entries []*entry
+	index   map[identity]*entry
+}
+
+// New creates UnitOfWork flushing aggregates in order of kinds, kinds not
+// listed are ordered after listed ones. Aggregates of the same kind are
+// flushed in order of registration.
+func New(kinds ...string) *UnitOfWork {
+	order := make(map[string]int, len(kinds))
+	for i, k := range kinds {
+		order[k] = i
+	}
+	return &UnitOfWork{order: order, index: make(map[identity]*entry)}
+}
+
+// RegisterNew registers aggregate to be inserted.
+func (u *UnitOfWork) RegisterNew(a Aggregate) {
+	u.register(a, OpInsert)
+}
+
+// RegisterDirty registers aggregate to be updated, it is no-op for aggregate
+// registered as new or deleted.
+func (u *UnitOfWork) RegisterDirty(a Aggregate) {
+	u.register(a, OpUpdate)
+}
+
+// RegisterDeleted registers aggregate to be deleted, aggregate registered as
+// new is forgotten instead.
+func (u *UnitOfWork) RegisterDeleted(a Aggregate) {
+	u.register(a, OpDelete)
+}
+
+func (u *UnitOfWork) register(a Aggregate, op Op) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	id := identity{kind: a.Kind(), id: a.ID()}
+
+	e, ok := u.index[id]
+	if !ok {
+		e = &entry{agg: a, op: op}
+		u.index[id] = e
+		u.entries = append(u.entries, e)
+		return
+	}
+
+	e.agg = a
+	switch {
+	case e.op == OpInsert && op == OpDelete:
+		delete(u.index, id)
+		u.entries = slices.DeleteFunc(u.entries, func(x *entry) bool { return x == e })
+	case op == OpDelete:
+		e.op = OpDelete
+	}
+}
+
+// Len returns number of pending changes.
+func (u *UnitOfWork) Len() int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return len(u.entries)
+}
+
+// Flush sends pending changes in one batch using transaction from ctx,
+// pgxatomic.ErrNoTx is returned if there is none. *ConflictError is returned
+// if update or delete affected no rows. Pending changes are cleared on success.
+func (u *UnitOfWork) Flush(ctx context.Context) error {
+	tx := pgxatomic.TxFromContext(ctx)
+	if tx == nil {
+		return pgxatomic.ErrNoTx
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if len(u.entries) == 0 {
+		return nil
+	}
+
+	entries := u.sorted()
+
+	b := &pgx.Batch{}
+	for _, e := range entries {
+		var (
+			sql  string
+			args []any
+		)
+		switch e.op {
+		case OpInsert:
+			sql, args = e.agg.InsertSQL()
+		case OpUpdate:
+			sql, args = e.agg.UpdateSQL()
+		case OpDelete:
+			sql, args = e.agg.DeleteSQL()
+		}
+		b.Queue(sql, args...)
+	}
+
+	res := tx.SendBatch(ctx, b)
+	for _, e := range entries {
+		tag, err := res.Exec()
+		if err != nil {
+			res.Close()
+			return fmt.Errorf("uow: %s of %s %v: %w", e.op, e.agg.Kind(), e.agg.ID(), err)
+		}
+		if e.op != OpInsert && tag.RowsAffected() == 0 {
+			res.Close()
+			return &ConflictError{Kind: e.agg.Kind(), ID: e.agg.ID(), Version: e.agg.Version(), Op: e.op}
+		}
+	}
+	if err := res.Close(); err != nil {
+		return err
+	}
+
+	u.entries = nil
+	clear(u.index)
+	return nil
+}
+
+// sorted returns inserts and updates in order of kinds followed by deletes in
+// reverse order of kinds.
+func (u *UnitOfWork) sorted() []*entry {
+	rank := func(e *entry) int {
+		if r, ok := u.order[e.agg.Kind()]; ok {
+			return r
+		}
+		return len(u.order)
+	}
+
+	var writes, deletes []*entry
+	for _, e := range u.entries {
+		if e.op == OpDelete {
+			deletes = append(deletes, e)
+		} else {
+			writes = append(writes, e)
+		}
+	}
+
+	slices.SortStableFunc(writes, func(a, b *entry) int { return rank(a) - rank(b) })
+	slices.SortStableFunc(deletes, func(a, b *entry) int { return rank(b) - rank(a) })
+
+	return append(writes, deletes...)
+}
+
+type ctxKey struct{}
+
+// WithUnitOfWork returns context carrying u, so repositories can register
+// changes without passing it explicitly.
+func WithUnitOfWork(ctx context.Context, u *UnitOfWork) context.Context {
+	return context.WithValue(ctx, ctxKey{}, u)
+}
+
+// FromContext returns UnitOfWork from ctx or nil if there is none.
+func FromContext(ctx context.Context) *UnitOfWork {
+	u, _ := ctx.Value(ctxKey{}).(*UnitOfWork)
+	return u
+}
+
+// Run runs fn in transaction of runner with new UnitOfWork in context and
+// flushes it before commit. Kinds define flush order as in New.
+func Run(ctx context.Context, runner pgxatomic.TxRunner, kinds []string, fn func(ctx context.Context, u *UnitOfWork) error, opts ...pgxatomic.RunOption) error {
+	return runner.Run(ctx, func(ctx context.Context) error {
+		u := New(kinds...)
+		ctx = WithUnitOfWork(ctx, u)
+		if err := fn(ctx, u); err != nil {
+			return err
+		}
+		return u.Flush(ctx)
+	}, opts...)
+}
@@ -0,0 +1,115 @@
+package uow
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ysomad/pgxatomic"
+	"github.com/ysomad/pgxatomic/pgxatomictest"
+)
+
+type aggregate struct {
+	kind    string
+	id      int
+	version int64
+}
+
+func (a aggregate) Kind() string   { return a.kind }
+func (a aggregate) ID() any        { return a.id }
+func (a aggregate) Version() int64 { return a.version }
+
+func (a aggregate) InsertSQL() (string, []any) {
+	return fmt.Sprintf("INSERT %s %d", a.kind, a.id), nil
+}
+
+func (a aggregate) UpdateSQL() (string, []any) {
+	return fmt.Sprintf("UPDATE %s %d", a.kind, a.id), []any{a.version}
+}
+
+func (a aggregate) DeleteSQL() (string, []any) {
+	return fmt.Sprintf("DELETE %s %d", a.kind, a.id), []any{a.version}
+}
+
+// affected returns ExecFunc reporting one affected row except for statements
+// with prefix of stale.
+func affected(stale ...string) func(context.Context, string, ...any) (pgconn.CommandTag, error) {
+	return func(_ context.Context, sql string, _ ...any) (pgconn.CommandTag, error) {
+		for _, s := range stale {
+			if strings.HasPrefix(sql, s) {
+				return pgconn.NewCommandTag("UPDATE 0"), nil
+			}
+		}
+		return pgconn.NewCommandTag("UPDATE 1"), nil
+	}
+}
+
+func TestUnitOfWork_Flush(t *testing.T) {
+	runner := &pgxatomictest.FakeRunner{NewTx: func() *pgxatomictest.RecordingTx {
+		tx := pgxatomictest.NewRecordingTx()
+		tx.ExecFunc = affected()
+		return tx
+	}}
+
+	err := Run(context.Background(), runner, []string{"customer", "order", "line"}, func(ctx context.Context, u *UnitOfWork) error {
+		u.RegisterNew(aggregate{kind: "line", id: 1})
+		u.RegisterDeleted(aggregate{kind: "customer", id: 2, version: 1})
+		u.RegisterNew(aggregate{kind: "order", id: 1})
+		u.RegisterDirty(aggregate{kind: "customer", id: 1, version: 3})
+		u.RegisterDeleted(aggregate{kind: "line", id: 2, version: 1})
+		u.RegisterNew(aggregate{kind: "note", id: 1})
+
+		// new and dirty stays new, new and deleted is forgotten
+		u.RegisterDirty(aggregate{kind: "order", id: 1})
+		u.RegisterNew(aggregate{kind: "order", id: 2})
+		u.RegisterDeleted(aggregate{kind: "order", id: 2})
+
+		assert.Same(t, u, FromContext(ctx))
+		assert.Equal(t, 6, u.Len())
+		return nil
+	})
+	require.NoError(t, err)
+
+	pgxatomictest.AssertCommitted(t, runner)
+	assert.Equal(t, []string{
+		"UPDATE customer 1",
+		"INSERT order 1",
+		"INSERT line 1",
+		"INSERT note 1",
+		"DELETE line 2",
+		"DELETE customer 2",
+		"COMMIT",
+	}, runner.LastRun().Tx.SQL())
+}
+
+func TestUnitOfWork_Conflict(t *testing.T) {
+	runner := &pgxatomictest.FakeRunner{NewTx: func() *pgxatomictest.RecordingTx {
+		tx := pgxatomictest.NewRecordingTx()
+		tx.ExecFunc = affected("UPDATE order")
+		return tx
+	}}
+
+	err := Run(context.Background(), runner, nil, func(ctx context.Context, u *UnitOfWork) error {
+		u.RegisterDirty(aggregate{kind: "order", id: 7, version: 3})
+		return nil
+	})
+
+	var conflict *ConflictError
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, &ConflictError{Kind: "order", ID: 7, Version: 3, Op: OpUpdate}, conflict)
+	assert.EqualError(t, err, "uow: update of order 7 version 3: version conflict")
+	assert.ErrorIs(t, err, pgxatomic.ErrStaleVersion)
+	pgxatomictest.AssertRolledBack(t, runner)
+}
+
+func TestUnitOfWork_FlushNoTx(t *testing.T) {
+	u := New()
+	u.RegisterNew(aggregate{kind: "order", id: 1})
+	assert.ErrorIs(t, u.Flush(context.Background()), pgxatomic.ErrNoTx)
+	assert.Equal(t, 1, u.Len())
+}
@@ -0,0 +1,35 @@
+package pgxatomic
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrStaleVersion is returned if versioned row was changed or deleted since it was read.
+var ErrStaleVersion = errors.New("pgxatomic: stale version")
+
+// UpdateVersioned executes sql updating row only if its version equals
+// expectedVersion, which is passed after args so sql must reference it as the
+// last placeholder. ErrStaleVersion is returned if no rows are affected.
+func UpdateVersioned(ctx context.Context, db executor, sql string, args []any, expectedVersion int64) error {
+	tag, err := Exec(ctx, db, sql, append(args[:len(args):len(args)], expectedVersion)...)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrStaleVersion
+	}
+	return nil
+}
+
+// RetryOnConflict runs read-modify-write fn in transaction of runner and
+// reruns it in new transaction while it fails with ErrStaleVersion, up to
+// maxAttempts times in total.
+func RetryOnConflict(ctx context.Context, runner TxRunner, maxAttempts int, fn func(ctx context.Context) error, opts ...RunOption) error {
+	for attempt := 1; ; attempt++ {
+		err := runner.Run(ctx, fn, opts...)
+		if err == nil || attempt >= maxAttempts || ctx.Err() != nil || !errors.Is(err, ErrStaleVersion) {
+			return err
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package pgxatomic
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestUpdateVersioned(t *testing.T) {
+	const sql = "UPDATE orders SET cost = $1, version = version + 1 WHERE id = $2 AND version = $3"
+
+	tests := []struct {
+		name    string
+		tag     pgconn.CommandTag
+		err     error
+		wantErr error
+	}{
+		{name: "updated", tag: pgconn.NewCommandTag("UPDATE 1")},
+		{name: "stale", tag: pgconn.NewCommandTag("UPDATE 0"), wantErr: ErrStaleVersion},
+		{name: "error", err: errTest, wantErr: errTest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			tx := NewMockTx(ctrl)
+			tx.EXPECT().Exec(gomock.Any(), sql, 100, 1, int64(3)).Return(tt.tag, tt.err)
+
+			args := make([]any, 2, 8)
+			args[0], args[1] = 100, 1
+
+			err := UpdateVersioned(WithTx(context.Background(), tx), nil, sql, args, 3)
+			assert.ErrorIs(t, err, tt.wantErr)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, []any{100, 1, nil}, args[:3], "args must not be modified")
+		})
+	}
+}
+
+func TestRetryOnConflict(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxAttempts  int
+		errs         []error
+		wantAttempts int
+		wantErr      error
+	}{
+		{name: "ok", maxAttempts: 3, errs: []error{nil}, wantAttempts: 1},
+		{name: "retried", maxAttempts: 3, errs: []error{ErrStaleVersion, ErrStaleVersion, nil}, wantAttempts: 3},
+		{name: "exhausted", maxAttempts: 2, errs: []error{ErrStaleVersion, ErrStaleVersion}, wantAttempts: 2, wantErr: ErrStaleVersion},
+		{name: "not retried", maxAttempts: 3, errs: []error{errTest}, wantAttempts: 1, wantErr: errTest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			db := NewMockTxStarter(ctrl)
+			for _, err := range tt.errs {
+				tx := NewMockTx(ctrl)
+				db.EXPECT().BeginTx(gomock.Any(), gomock.Any()).Return(tx, nil)
+				if err == nil {
+					tx.EXPECT().Commit(gomock.Any()).Return(nil)
+				} else {
+					tx.EXPECT().Rollback(gomock.Any()).Return(nil)
+				}
+			}
+
+			runner, err := NewRunner(db, pgx.TxOptions{})
+			assert.NoError(t, err)
+
+			var attempts int
+			err = RetryOnConflict(context.Background(), runner, tt.maxAttempts, func(ctx context.Context) error {
+				attempts++
+				return tt.errs[attempts-1]
+			})
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.wantAttempts, attempts)
+		})
+	}
+}